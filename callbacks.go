@@ -0,0 +1,116 @@
+package vte
+
+/*
+#include <stdint.h>
+#include <glib.h>
+
+static gpointer  handleToGpointer (uintptr_t h) { return (gpointer)h; }
+static uintptr_t gpointerToHandle (gpointer p)  { return (uintptr_t)p; }
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"sync"
+)
+
+// callbackInfo describes a Go callback given to C.
+//
+type callbackInfo struct {
+	kind     string
+	terminal *C.VteTerminal
+}
+
+// releaser is implemented by callbacks holding resources to free when they
+// are dropped without being called.
+//
+type releaser interface {
+	release()
+}
+
+var liveCallbacks = make(map[cgo.Handle]callbackInfo)
+var liveCallbacksMU = sync.Mutex{}
+
+// newCallback registers a Go value given as user data to a C callback of the
+// terminal. The kind groups callbacks in LiveCallbacks.
+//
+// The callback is released when taken, or when the terminal is finalized.
+//
+func (v *Terminal) newCallback(kind string, value interface{}) C.gpointer {
+	v.state() // Watch the terminal finalize.
+	return registerCallback(v.ptr, kind, value)
+}
+
+// registerCallback registers a Go value given as user data to a C callback of
+// the terminal, which must have its state.
+//
+func registerCallback(terminal *C.VteTerminal, kind string, value interface{}) C.gpointer {
+	h := cgo.NewHandle(value)
+	liveCallbacksMU.Lock()
+	liveCallbacks[h] = callbackInfo{kind: kind, terminal: terminal}
+	liveCallbacksMU.Unlock()
+	return C.handleToGpointer(C.uintptr_t(h))
+}
+
+// lookupCallback returns the Go value of the C user data, or nil if released.
+//
+func lookupCallback(data C.gpointer) interface{} {
+	h := cgo.Handle(C.gpointerToHandle(data))
+	liveCallbacksMU.Lock()
+	defer liveCallbacksMU.Unlock()
+	if _, ok := liveCallbacks[h]; !ok {
+		return nil
+	}
+	return h.Value()
+}
+
+// takeCallback returns the Go value of the C user data and releases it.
+// Returns nil if already released.
+//
+func takeCallback(data C.gpointer) interface{} {
+	h := cgo.Handle(C.gpointerToHandle(data))
+	liveCallbacksMU.Lock()
+	defer liveCallbacksMU.Unlock()
+	if _, ok := liveCallbacks[h]; !ok {
+		return nil
+	}
+	delete(liveCallbacks, h)
+	value := h.Value()
+	h.Delete()
+	return value
+}
+
+// releaseTerminalCallbacks drops all callbacks of the terminal.
+//
+func releaseTerminalCallbacks(terminal *C.VteTerminal) {
+	var values []interface{}
+	liveCallbacksMU.Lock()
+	for h, info := range liveCallbacks {
+		if info.terminal == terminal {
+			values = append(values, h.Value())
+			delete(liveCallbacks, h)
+			h.Delete()
+		}
+	}
+	liveCallbacksMU.Unlock()
+
+	for _, value := range values {
+		if r, ok := value.(releaser); ok {
+			r.release()
+		}
+	}
+}
+
+// LiveCallbacks returns the number of Go callbacks currently given to C, by
+// kind. It's a debug helper to check for leaks: callbacks of a finalized
+// terminal are all released.
+//
+func LiveCallbacks() map[string]int {
+	liveCallbacksMU.Lock()
+	defer liveCallbacksMU.Unlock()
+	count := make(map[string]int)
+	for _, info := range liveCallbacks {
+		count[info.kind]++
+	}
+	return count
+}
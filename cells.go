@@ -0,0 +1,104 @@
+package vte
+
+/*
+#include <vte/vte.h>
+
+static GArray * newAttributes () {
+	return g_array_new(FALSE, FALSE, sizeof(VteCharAttributes));
+}
+
+static VteCharAttributes * attributeAt (GArray *attributes, guint i) {
+	return &g_array_index(attributes, VteCharAttributes, i);
+}
+
+// Bit fields aren't reachable from Go.
+static int attrUnderline     (VteCharAttributes *attr) { return attr->underline; }
+static int attrStrikethrough (VteCharAttributes *attr) { return attr->strikethrough; }
+static int attrColumns       (VteCharAttributes *attr) { return attr->columns; }
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Color is a 16 bits per channel RGB color.
+//
+type Color struct {
+	Red, Green, Blue uint16
+}
+
+// String returns the color as "#rrggbb".
+//
+func (c Color) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.Red>>8, c.Green>>8, c.Blue>>8)
+}
+
+// Cell is a character of the terminal with its attributes.
+//
+type Cell struct {
+	Rune          rune
+	Column        int   // Column is the column of the cell.
+	Columns       int   // Columns is the number of columns used by the character (2 for wide ones).
+	Fg            Color // Fg is the foreground color.
+	Bg            Color // Bg is the background color.
+	Underline     bool
+	Strikethrough bool
+}
+
+// GetCells extracts the text of the range with its attributes, as a list of
+// cells for each row. Coordinates are the same as GetTextRange ones.
+//
+// Returns ErrUnsupported if Vte doesn't provide the attributes, as recent
+// versions dropped them.
+//
+func (v *Terminal) GetCells(startRow, startCol, endRow, endCol int32) ([][]Cell, error) {
+	if endRow < startRow {
+		return nil, errors.New("invalid cell range: end row before start row")
+	}
+
+	attrs := C.newAttributes()
+	defer C.g_array_free(attrs, C.TRUE)
+
+	ctext := C.vte_terminal_get_text_range(v.Native(),
+		C.glong(startRow),
+		C.glong(startCol),
+		C.glong(endRow),
+		C.glong(endCol),
+		nil,
+		nil,
+		attrs)
+	if ctext == nil { // Newer Vte versions refuse the attributes array.
+		return nil, ErrUnsupported
+	}
+	text := C.GoString(ctext)
+	C.g_free(C.gpointer(unsafe.Pointer(ctext)))
+
+	if len(text) > 0 && int(attrs.len) == 0 {
+		return nil, ErrUnsupported
+	}
+
+	rows := make([][]Cell, endRow-startRow+1)
+	for i, r := range text { // Vte sets the attributes of each byte.
+		if r == '\n' || i >= int(attrs.len) {
+			continue
+		}
+		attr := C.attributeAt(attrs, C.guint(i))
+		row := int(attr.row) - int(startRow)
+		if row < 0 || row >= len(rows) {
+			continue
+		}
+		rows[row] = append(rows[row], Cell{
+			Rune:          r,
+			Column:        int(attr.column),
+			Columns:       int(C.attrColumns(attr)),
+			Fg:            Color{uint16(attr.fore.red), uint16(attr.fore.green), uint16(attr.fore.blue)},
+			Bg:            Color{uint16(attr.back.red), uint16(attr.back.green), uint16(attr.back.blue)},
+			Underline:     C.attrUnderline(attr) != 0,
+			Strikethrough: C.attrStrikethrough(attr) != 0,
+		})
+	}
+	return rows, nil
+}
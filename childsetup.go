@@ -0,0 +1,295 @@
+package vte
+
+/*
+#include <errno.h>
+#include <fcntl.h>
+#include <stdlib.h>
+#include <unistd.h>
+#include <grp.h>
+#include <signal.h>
+#include <sys/types.h>
+#include <sys/stat.h>
+#include <sys/resource.h>
+#include <glib.h>
+
+#ifdef __linux__
+#include <sys/prctl.h>
+#include <sys/syscall.h>
+#ifndef CLOSE_RANGE_CLOEXEC
+#define CLOSE_RANGE_CLOEXEC (1U << 2)
+#endif
+#endif
+
+typedef struct {
+	int      resource;
+	rlim_t   cur;
+	rlim_t   max;
+} child_rlimit;
+
+typedef struct {
+	gboolean      setsid;
+	gboolean      set_umask;
+	mode_t        umask;
+	int           nice;
+	gboolean      set_cred;
+	uid_t         uid;
+	gid_t         gid;
+	gboolean      set_groups;
+	gid_t        *groups;
+	int           n_groups;
+	int           pdeathsig;
+	pid_t         ppid;       // Pid of the parent, to detect its death before pdeathsig is set.
+	int           close_from; // Mark descriptors from this one close-on-exec, if > 2.
+	child_rlimit *rlimits;
+	int           n_rlimits;
+	int           err_fd;     // Close-on-exec pipe reporting a failed setup to the parent.
+} child_setup_data;
+
+// Setup steps reported on failure.
+enum {
+	SETUP_PDEATHSIG = 1,
+	SETUP_SETRLIMIT,
+	SETUP_SETGROUPS,
+	SETUP_SETGID,
+	SETUP_SETUID,
+};
+
+// setupFailed reports the failed step and errno to the parent, then exits.
+// The pipe is closed by exec on success, so the parent reads nothing.
+static void setupFailed(child_setup_data *d, int step) {
+	int msg[2] = { step, errno };
+	ssize_t n;
+	do {
+		n = write(d->err_fd, msg, sizeof(msg));
+	} while (n < 0 && errno == EINTR);
+	_exit(127);
+}
+
+// cloexecFrom marks the descriptors from the given one close-on-exec. The
+// spawn reports exec errors on its own pipe, they can't just be closed.
+static void cloexecFrom(int from) {
+	struct rlimit lim;
+	int fd, max = 1024;
+
+#if defined(__linux__) && defined(SYS_close_range)
+	if (syscall(SYS_close_range, from, ~0U, CLOSE_RANGE_CLOEXEC) == 0) {
+		return; // Linux 5.11+.
+	}
+#endif
+
+	if (getrlimit(RLIMIT_NOFILE, &lim) == 0 && lim.rlim_cur != RLIM_INFINITY) {
+		max = lim.rlim_cur;
+	}
+	for (fd = from; fd < max; fd++) {
+		int flags = fcntl(fd, F_GETFD);
+		if (flags >= 0 && !(flags & FD_CLOEXEC)) {
+			fcntl(fd, F_SETFD, flags | FD_CLOEXEC);
+		}
+	}
+}
+
+// childSetup runs in the child between fork and exec.
+// Only async-signal-safe calls are allowed here.
+static void childSetup(gpointer p) {
+	child_setup_data *d = p;
+	int i;
+
+	if (d->setsid) {
+		setsid(); // Fails when Vte already made the child a session leader.
+	}
+
+#ifdef __linux__
+	if (d->pdeathsig > 0) {
+		if (prctl(PR_SET_PDEATHSIG, d->pdeathsig) != 0) {
+			setupFailed(d, SETUP_PDEATHSIG);
+		}
+		if (getppid() != d->ppid) { // The parent died before the signal was armed.
+			kill(getpid(), d->pdeathsig);
+		}
+	}
+#endif
+
+	// Before the rlimits, which can lower the descriptor limit walked.
+	if (d->close_from > 2) {
+		cloexecFrom(d->close_from);
+	}
+
+	for (i = 0; i < d->n_rlimits; i++) {
+		struct rlimit lim = { d->rlimits[i].cur, d->rlimits[i].max };
+		if (setrlimit(d->rlimits[i].resource, &lim) != 0) {
+			setupFailed(d, SETUP_SETRLIMIT);
+		}
+	}
+
+	if (d->nice != 0) {
+		nice(d->nice);
+	}
+
+	if (d->set_umask) {
+		umask(d->umask);
+	}
+
+	// Drop privileges last, the other settings may need them.
+	if (d->set_cred) {
+		if (d->set_groups && setgroups(d->n_groups, d->groups) != 0) {
+			setupFailed(d, SETUP_SETGROUPS);
+		}
+		if (setgid(d->gid) != 0) {
+			setupFailed(d, SETUP_SETGID);
+		}
+		if (setuid(d->uid) != 0) {
+			setupFailed(d, SETUP_SETUID);
+		}
+	}
+}
+
+// freeChildSetup releases the data once the child was forked, with the write
+// end of the error pipe.
+static void freeChildSetup(gpointer p) {
+	child_setup_data *d = p;
+	close(d->err_fd);
+	free(d->groups);
+	free(d->rlimits);
+	free(d);
+}
+
+static GSpawnChildSetupFunc childSetupFunc()   { return childSetup; }
+static GDestroyNotify       freeChildSetupFunc() { return freeChildSetup; }
+*/
+import "C"
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// SysProcAttr holds settings applied to a command between fork and exec, by a
+// C routine as Go code can't run there. Zero values leave the setting unchanged.
+// A failed setup is reported as a spawn error, like a failed exec.
+//
+// The kernel sends Pdeathsig when the thread that forked the child exits, not
+// the process. Vte 0.48 and later fork from a GLib worker thread, which can be
+// retired while the application still runs: with those versions, the child
+// can get the signal at any time. Only use Pdeathsig with commands that can be
+// restarted, like the ones of a Supervisor.
+//
+// Vte already closes the descriptors that aren't passed to the child, unless
+// SpawnLeaveDescriptorsOpen is set (only allowed before Vte 0.62): CloseFDs is
+// only needed with that flag.
+//
+type SysProcAttr struct {
+	Setsid     bool                // Setsid starts a new session, useful with PtyNoSession.
+	SetUmask   bool                // SetUmask applies Umask to the child.
+	Umask      uint32              // Umask is the file mode creation mask, if SetUmask.
+	Nice       int                 // Nice is the increment added to the child nice value.
+	Credential *syscall.Credential // Credential holds user and groups identities to switch to.
+	Pdeathsig  syscall.Signal      // Pdeathsig is sent to the child when the thread that forked it exits (Linux only).
+	Rlimits    []Rlimit            // Rlimits lists resource limits to set.
+	CloseFDs   bool                // CloseFDs closes all descriptors above stderr and ExtraFiles at exec.
+}
+
+// Rlimit defines a resource limit to set in the child.
+//
+type Rlimit struct {
+	Resource int    // Resource to limit, like syscall.RLIMIT_NOFILE.
+	Cur      uint64 // Cur is the soft limit.
+	Max      uint64 // Max is the hard limit.
+}
+
+// setupSteps names the child setup steps, for the errors.
+//
+var setupSteps = map[C.int]string{
+	C.SETUP_PDEATHSIG: "prctl",
+	C.SETUP_SETRLIMIT: "setrlimit",
+	C.SETUP_SETGROUPS: "setgroups",
+	C.SETUP_SETGID:    "setgid",
+	C.SETUP_SETUID:    "setuid",
+}
+
+// childSetup returns the child setup arguments for a spawn call, keeping
+// extraFiles descriptors after stderr open. The data is freed by the returned
+// destroy notify.
+//
+// The returned descriptor reads the failures of the setup, see setupError.
+// It's -1 when no setup is needed.
+//
+func (attr *SysProcAttr) childSetup(extraFiles int) (C.GSpawnChildSetupFunc, C.gpointer, C.GDestroyNotify, int, error) {
+	if attr == nil {
+		return nil, nil, nil, -1, nil
+	}
+
+	var fds [2]int
+	if e := syscall.Pipe2(fds[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK); e != nil {
+		return nil, nil, nil, -1, os.NewSyscallError("pipe2", e)
+	}
+
+	d := (*C.child_setup_data)(C.calloc(1, C.sizeof_child_setup_data))
+	d.err_fd = C.int(fds[1])
+	d.ppid = C.pid_t(os.Getpid())
+	d.setsid = cbool(attr.Setsid)
+	d.set_umask = cbool(attr.SetUmask)
+	d.umask = C.mode_t(attr.Umask)
+	d.nice = C.int(attr.Nice)
+	d.pdeathsig = C.int(attr.Pdeathsig)
+	if attr.CloseFDs {
+		d.close_from = C.int(3 + extraFiles)
+	}
+
+	if cred := attr.Credential; cred != nil {
+		d.set_cred = cbool(true)
+		d.uid = C.uid_t(cred.Uid)
+		d.gid = C.gid_t(cred.Gid)
+		if !cred.NoSetGroups {
+			d.set_groups = cbool(true)
+			d.n_groups = C.int(len(cred.Groups))
+			if len(cred.Groups) > 0 {
+				d.groups = (*C.gid_t)(C.calloc(C.size_t(len(cred.Groups)), C.sizeof_gid_t))
+				groups := unsafe.Slice(d.groups, len(cred.Groups))
+				for i, gid := range cred.Groups {
+					groups[i] = C.gid_t(gid)
+				}
+			}
+		}
+	}
+
+	if len(attr.Rlimits) > 0 {
+		d.n_rlimits = C.int(len(attr.Rlimits))
+		d.rlimits = (*C.child_rlimit)(C.calloc(C.size_t(len(attr.Rlimits)), C.sizeof_child_rlimit))
+		limits := unsafe.Slice(d.rlimits, len(attr.Rlimits))
+		for i, lim := range attr.Rlimits {
+			limits[i].resource = C.int(lim.Resource)
+			limits[i].cur = C.rlim_t(lim.Cur)
+			limits[i].max = C.rlim_t(lim.Max)
+		}
+	}
+
+	return C.childSetupFunc(), C.gpointer(unsafe.Pointer(d)), C.freeChildSetupFunc(), fds[0], nil
+}
+
+// freeChildSetupData frees the child setup data of a spawn that didn't start.
+//
+func freeChildSetupData(data C.gpointer) {
+	if data != nil {
+		C.freeChildSetup(data)
+	}
+}
+
+// setupError returns the error reported by the child setup on the pipe, if
+// any, and closes it. The spawn only completes once the child exec'd or
+// exited, so the report is already written: the read never waits.
+//
+func setupError(errFd int) error {
+	if errFd < 0 {
+		return nil
+	}
+	defer syscall.Close(errFd)
+
+	var msg [2]C.int
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&msg[0])), unsafe.Sizeof(msg))
+	n, _ := syscall.Read(errFd, buf)
+	if n != len(buf) {
+		return nil // Closed by exec.
+	}
+	return os.NewSyscallError(setupSteps[msg[0]], syscall.Errno(msg[1]))
+}
@@ -0,0 +1,286 @@
+package vte
+
+/*
+#include <vte/vte.h>
+
+static GtkAdjustment * vadjustment (VteTerminal *terminal) {
+	return gtk_scrollable_get_vadjustment(GTK_SCROLLABLE(terminal));
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCommands is the number of commands kept by the tracker.
+//
+const maxCommands = 10000
+
+// statusReport is the device status report query sent to Vte after each
+// shell mark. Vte answers it while parsing, which locates the mark.
+//
+const statusReport = "\x1b[5n"
+
+// statusReportOK is the answer of Vte to statusReport.
+//
+const statusReportOK = "\x1b[0n"
+
+// Command describes a command run by a shell with FinalTerm (OSC 133) marks.
+// Rows are absolute, like GetCursorPosition ones.
+//
+type Command struct {
+	PromptRow   int    // PromptRow is the row of the prompt start.
+	CommandLine string // CommandLine is the text typed at the prompt, set once executed.
+	OutputStart int    // OutputStart is the first row of the output, or -1 before the command runs.
+	OutputEnd   int    // OutputEnd is the row after the output, or -1 until the command ends.
+	ExitCode    int    // ExitCode is the exit status reported by the shell, or -1 if unknown.
+}
+
+// Running reports whether the command was started and didn't end yet.
+//
+func (c Command) Running() bool {
+	return c.OutputStart >= 0 && c.OutputEnd < 0
+}
+
+// shellMark is a FinalTerm mark waiting for its position.
+//
+type shellMark struct {
+	kind     byte // 'A' prompt start, 'B' command start, 'C' output start, 'D' command end.
+	exitCode int
+}
+
+// parseShellMark returns the FinalTerm mark of the OSC payload, or nil.
+//
+func parseShellMark(payload []byte) *shellMark {
+	fields := strings.Split(string(payload), ";")
+	if len(fields) < 2 || fields[0] != "133" || len(fields[1]) != 1 {
+		return nil
+	}
+	mark := &shellMark{kind: fields[1][0], exitCode: -1}
+	switch mark.kind {
+	case 'A', 'B', 'C':
+	case 'D':
+		if len(fields) > 2 {
+			if code, e := strconv.Atoi(fields[2]); e == nil {
+				mark.exitCode = code
+			}
+		}
+	default:
+		return nil
+	}
+	return mark
+}
+
+// screen reads the terminal text for the tracker, implemented by Terminal.
+//
+type screen interface {
+	GetColumnCount() int
+	GetTextRange(startRow, startCol, endRow, endCol int32) string
+}
+
+// commandTracker builds the command list from the shell marks.
+//
+type commandTracker struct {
+	mu       sync.Mutex
+	commands []Command
+
+	// Main loop only.
+	queue              []*shellMark // Marks waiting for the status report, nil for the child ones.
+	inputRow, inputCol int          // Position of the command line of the last command.
+}
+
+// TrackCommands enables the command tracking of shells emitting FinalTerm
+// (OSC 133) marks, for commands started after the call. Like output taps, it
+// relays the PTY of the child (Vte 0.48+), with the same side effects.
+//
+func (v *Terminal) TrackCommands() {
+	st := v.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.commands == nil {
+		st.commands = &commandTracker{}
+	}
+}
+
+// StopTrackingCommands disables the command tracking and drops the tracked
+// commands. Commands started afterwards are no longer relayed, unless output
+// taps or sequence hooks need it.
+//
+func (v *Terminal) StopTrackingCommands() {
+	st := v.state()
+	st.mu.Lock()
+	st.commands = nil
+	st.mu.Unlock()
+}
+
+// Commands returns the commands tracked in the terminal, oldest first.
+//
+func (v *Terminal) Commands() []Command {
+	st := v.state()
+	st.mu.Lock()
+	tr := st.commands
+	st.mu.Unlock()
+	if tr == nil {
+		return nil
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]Command(nil), tr.commands...)
+}
+
+// CommandOutput returns the output text of the tracked command i, as listed by
+// Commands. The output of a running command is read up to the cursor.
+//
+func (v *Terminal) CommandOutput(i int) (string, error) {
+	cmds := v.Commands()
+	if i < 0 || i >= len(cmds) {
+		return "", errors.New("no command " + strconv.Itoa(i))
+	}
+	c := cmds[i]
+	if c.OutputStart < 0 {
+		return "", errors.New("command not started")
+	}
+	end := c.OutputEnd
+	if end < 0 {
+		_, row := v.GetCursorPosition()
+		end = int(row) + 1
+	}
+	if end <= c.OutputStart {
+		return "", nil
+	}
+	text := v.GetTextRange(int32(c.OutputStart), 0, int32(end-1), int32(v.GetColumnCount()-1))
+	return strings.TrimRight(text, "\n"), nil
+}
+
+// JumpToPreviousPrompt scrolls the terminal to the prompt above the top of the
+// view. Returns false if there is none.
+//
+func (v *Terminal) JumpToPreviousPrompt() bool {
+	top := v.scrollRow()
+	cmds := v.Commands()
+	for i := len(cmds) - 1; i >= 0; i-- {
+		if cmds[i].PromptRow < top {
+			v.scrollToRow(cmds[i].PromptRow)
+			return true
+		}
+	}
+	return false
+}
+
+// JumpToNextPrompt scrolls the terminal to the prompt below the top of the
+// view. Returns false if there is none.
+//
+func (v *Terminal) JumpToNextPrompt() bool {
+	top := v.scrollRow()
+	for _, c := range v.Commands() {
+		if c.PromptRow > top {
+			v.scrollToRow(c.PromptRow)
+			return true
+		}
+	}
+	return false
+}
+
+// scrollRow returns the row at the top of the view.
+//
+func (v *Terminal) scrollRow() int {
+	return int(C.gtk_adjustment_get_value(C.vadjustment(v.Native())))
+}
+
+// scrollToRow scrolls the view to show the row at the top, or as close as
+// possible.
+//
+func (v *Terminal) scrollToRow(row int) {
+	C.gtk_adjustment_set_value(C.vadjustment(v.Native()), C.gdouble(row))
+}
+
+// feed queues the mark, to be located by the status report fed after it.
+//
+func (tr *commandTracker) feed(mark *shellMark) {
+	tr.queue = append(tr.queue, mark)
+}
+
+// takeReply handles a status report answer sent by the terminal. Returns true
+// if it answers a shell mark, and must not reach the child.
+//
+func (tr *commandTracker) takeReply(v *Terminal, data []byte) bool {
+	if len(tr.queue) == 0 || string(data) != statusReportOK {
+		return false
+	}
+	mark := tr.queue[0]
+	tr.queue = tr.queue[1:]
+	if mark == nil {
+		return false // Asked by the child.
+	}
+
+	col, row := v.GetCursorPosition()
+	tr.mark(v, mark, int(col), int(row))
+	return true
+}
+
+// mark updates the commands with the mark found at the position. The command
+// line is read from the screen.
+//
+func (tr *commandTracker) mark(scr screen, mark *shellMark, col, row int) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	var cur *Command
+	if len(tr.commands) > 0 {
+		cur = &tr.commands[len(tr.commands)-1]
+	}
+
+	switch mark.kind {
+	case 'A':
+		if cur != nil && cur.Running() { // No end mark.
+			cur.OutputEnd = row
+		}
+		tr.add(row)
+
+	case 'B':
+		if cur == nil || cur.OutputStart >= 0 {
+			tr.add(row)
+		}
+		tr.inputRow, tr.inputCol = row, col
+
+	case 'C':
+		if cur == nil || cur.OutputStart >= 0 {
+			return
+		}
+		endRow, endCol := row, col-1
+		if col == 0 && row > tr.inputRow { // Ends with the newline typed.
+			endRow, endCol = row-1, scr.GetColumnCount()-1
+		}
+		if endCol >= 0 {
+			text := scr.GetTextRange(int32(tr.inputRow), int32(tr.inputCol), int32(endRow), int32(endCol))
+			cur.CommandLine = strings.TrimSpace(text)
+		}
+		cur.OutputStart = row
+
+	case 'D':
+		if cur == nil || cur.OutputEnd >= 0 {
+			return
+		}
+		if cur.OutputStart < 0 { // Empty command line.
+			cur.OutputStart = row
+		}
+		cur.OutputEnd = row
+		if col > 0 {
+			cur.OutputEnd = row + 1
+		}
+		cur.ExitCode = mark.exitCode
+	}
+}
+
+// add starts a new command at the prompt row.
+//
+func (tr *commandTracker) add(row int) {
+	if len(tr.commands) >= maxCommands {
+		tr.commands = append(tr.commands[:0:0], tr.commands[1:]...)
+	}
+	tr.commands = append(tr.commands, Command{PromptRow: row, OutputStart: -1, OutputEnd: -1, ExitCode: -1})
+}
@@ -0,0 +1,124 @@
+package vte
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseShellMark(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    *shellMark
+	}{
+		{"133;A", &shellMark{'A', -1}},
+		{"133;B", &shellMark{'B', -1}},
+		{"133;C", &shellMark{'C', -1}},
+		{"133;D", &shellMark{'D', -1}},
+		{"133;D;0", &shellMark{'D', 0}},
+		{"133;D;130", &shellMark{'D', 130}},
+		{"133;D;x", &shellMark{'D', -1}},
+		{"133;A;cl=m", &shellMark{'A', -1}},
+		{"133;E", nil},
+		{"133;AB", nil},
+		{"133", nil},
+		{"133;", nil},
+		{"7;file:///tmp", nil},
+	}
+	for _, test := range tests {
+		got := parseShellMark([]byte(test.payload))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseShellMark(%q) = %+v, want %+v", test.payload, got, test.want)
+		}
+	}
+}
+
+// fakeScreen returns the text of known ranges.
+//
+type fakeScreen struct {
+	cols int
+	text map[[4]int32]string
+}
+
+func (s fakeScreen) GetColumnCount() int { return s.cols }
+
+func (s fakeScreen) GetTextRange(startRow, startCol, endRow, endCol int32) string {
+	return s.text[[4]int32{startRow, startCol, endRow, endCol}]
+}
+
+// position is a mark found at a screen position.
+//
+type position struct {
+	mark     string
+	col, row int
+}
+
+func TestCommandTrackerMark(t *testing.T) {
+	scr := fakeScreen{cols: 80, text: map[[4]int32]string{
+		{0, 2, 0, 79}: "ls -l   ",
+		{3, 2, 3, 4}:  "pwd",
+		{6, 2, 6, 79}: "sleep 9",
+	}}
+
+	tests := []struct {
+		name  string
+		marks []position
+		want  []Command
+	}{
+		{"prompt", []position{{"A", 0, 0}},
+			[]Command{{PromptRow: 0, OutputStart: -1, OutputEnd: -1, ExitCode: -1}}},
+
+		{"command", []position{{"A", 0, 0}, {"B", 2, 0}, {"C", 0, 1}, {"D;0", 0, 3}},
+			[]Command{{PromptRow: 0, CommandLine: "ls -l", OutputStart: 1, OutputEnd: 3, ExitCode: 0}}},
+
+		{"output without newline", []position{{"A", 0, 0}, {"B", 2, 0}, {"C", 0, 1}, {"D;1", 5, 2}},
+			[]Command{{PromptRow: 0, CommandLine: "ls -l", OutputStart: 1, OutputEnd: 3, ExitCode: 1}}},
+
+		{"output on the command row", []position{{"A", 0, 3}, {"B", 2, 3}, {"C", 5, 3}, {"D;0", 0, 4}},
+			[]Command{{PromptRow: 3, CommandLine: "pwd", OutputStart: 3, OutputEnd: 4, ExitCode: 0}}},
+
+		{"running", []position{{"A", 0, 6}, {"B", 2, 6}, {"C", 0, 7}},
+			[]Command{{PromptRow: 6, CommandLine: "sleep 9", OutputStart: 7, OutputEnd: -1, ExitCode: -1}}},
+
+		{"no end mark", []position{{"A", 0, 0}, {"B", 2, 0}, {"C", 0, 1}, {"A", 0, 4}},
+			[]Command{
+				{PromptRow: 0, CommandLine: "ls -l", OutputStart: 1, OutputEnd: 4, ExitCode: -1},
+				{PromptRow: 4, OutputStart: -1, OutputEnd: -1, ExitCode: -1},
+			}},
+
+		{"empty command line", []position{{"A", 0, 0}, {"B", 2, 0}, {"D", 0, 1}},
+			[]Command{{PromptRow: 0, OutputStart: 1, OutputEnd: 1, ExitCode: -1}}},
+
+		{"command without prompt mark", []position{{"B", 2, 0}, {"C", 0, 1}},
+			[]Command{{PromptRow: 0, CommandLine: "ls -l", OutputStart: 1, OutputEnd: -1, ExitCode: -1}}},
+
+		{"marks out of order", []position{{"C", 0, 1}, {"D;0", 0, 2}},
+			nil},
+
+		{"repeated marks", []position{{"A", 0, 0}, {"B", 2, 0}, {"C", 0, 1}, {"C", 0, 2}, {"D;0", 0, 3}, {"D;1", 0, 4}},
+			[]Command{{PromptRow: 0, CommandLine: "ls -l", OutputStart: 1, OutputEnd: 3, ExitCode: 0}}},
+	}
+	for _, test := range tests {
+		var tr commandTracker
+		for _, pos := range test.marks {
+			mark := parseShellMark([]byte("133;" + pos.mark))
+			tr.mark(scr, mark, pos.col, pos.row)
+		}
+		if !reflect.DeepEqual(tr.commands, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, tr.commands, test.want)
+		}
+	}
+}
+
+func TestCommandTrackerLimit(t *testing.T) {
+	var tr commandTracker
+	scr := fakeScreen{cols: 80}
+	for row := 0; row <= maxCommands; row++ {
+		tr.mark(scr, &shellMark{kind: 'A'}, 0, row)
+	}
+	if len(tr.commands) != maxCommands {
+		t.Fatalf("got %d commands, want %d", len(tr.commands), maxCommands)
+	}
+	if tr.commands[0].PromptRow != 1 {
+		t.Errorf("oldest prompt row = %d, want 1", tr.commands[0].PromptRow)
+	}
+}
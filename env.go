@@ -0,0 +1,68 @@
+package vte
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// spawnNoParentEnv is the private Vte spawn flag (VTE_SPAWN_NO_PARENT_ENVV)
+// using the given environment as is. Without it, Vte merges the environment of
+// the command into the parent one, so variables could never be removed.
+//
+const spawnNoParentEnv SpawnFlags = 1 << 25
+
+// environ returns the full environment list of the command.
+//
+// The list starts with the parent environment when InheritEnv is set or
+// Environ is nil, then Environ and Env are applied in this order, a later value
+// replacing an earlier one of the same name. Variables named in Unsetenv are
+// removed last.
+//
+func (cmd Cmd) environ() []string {
+	var list []string
+	if cmd.InheritEnv || cmd.Environ == nil {
+		list = append(list, os.Environ()...)
+	}
+	list = append(list, cmd.Environ...)
+
+	keys := make([]string, 0, len(cmd.Env))
+	for k := range cmd.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // Map order is random, keep the result stable.
+	for _, k := range keys {
+		list = append(list, k+"="+cmd.Env[k])
+	}
+
+	return mergeEnv(list, cmd.Unsetenv)
+}
+
+// mergeEnv removes duplicate variables from the "key=value" list, keeping the
+// position of the first one and the value of the last one. Variables listed in
+// unset are dropped.
+//
+func mergeEnv(list, unset []string) []string {
+	skip := make(map[string]bool, len(unset))
+	for _, name := range unset {
+		skip[name] = true
+	}
+
+	out := make([]string, 0, len(list))
+	index := make(map[string]int, len(list))
+	for _, kv := range list {
+		name := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			name = kv[:i]
+		}
+		switch pos, dup := index[name]; {
+		case skip[name]:
+		case dup:
+			out[pos] = kv
+		default:
+			index[name] = len(out)
+			out = append(out, kv)
+		}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package vte
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMergeEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		list  []string
+		unset []string
+		want  []string
+	}{
+		{"empty", nil, nil, []string{}},
+		{"keep order", []string{"A=1", "B=2"}, nil, []string{"A=1", "B=2"}},
+		{"last value first position", []string{"A=1", "B=2", "A=3"}, nil, []string{"A=3", "B=2"}},
+		{"unset", []string{"A=1", "B=2", "C=3"}, []string{"B"}, []string{"A=1", "C=3"}},
+		{"unset all duplicates", []string{"A=1", "A=2"}, []string{"A"}, []string{}},
+		{"unset missing", []string{"A=1"}, []string{"Z"}, []string{"A=1"}},
+		{"no value", []string{"A", "A=1"}, nil, []string{"A=1"}},
+		{"equal in value", []string{"A=x=y"}, nil, []string{"A=x=y"}},
+	}
+	for _, test := range tests {
+		got := mergeEnv(test.list, test.unset)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: mergeEnv(%q, %q) = %q, want %q", test.name, test.list, test.unset, got, test.want)
+		}
+	}
+}
+
+func TestCmdEnviron(t *testing.T) {
+	os.Setenv("VTE_GO_TEST_PARENT", "parent")
+	defer os.Unsetenv("VTE_GO_TEST_PARENT")
+
+	tests := []struct {
+		name   string
+		cmd    Cmd
+		parent bool // The parent variable is kept.
+		want   map[string]string
+	}{
+		{"nil environ inherits", Cmd{}, true, nil},
+		{"empty environ", Cmd{Environ: []string{}}, false, nil},
+		{"environ only", Cmd{Environ: []string{"A=1"}}, false, map[string]string{"A": "1"}},
+		{"inherit and environ", Cmd{Environ: []string{"A=1"}, InheritEnv: true}, true, map[string]string{"A": "1"}},
+		{"env over environ", Cmd{Environ: []string{"A=1"}, Env: map[string]string{"A": "2"}}, false, map[string]string{"A": "2"}},
+		{"env over parent", Cmd{Env: map[string]string{"VTE_GO_TEST_PARENT": "env"}}, true, map[string]string{"VTE_GO_TEST_PARENT": "env"}},
+		{"unset parent", Cmd{Unsetenv: []string{"VTE_GO_TEST_PARENT"}}, false, nil},
+	}
+	for _, test := range tests {
+		got := make(map[string]string)
+		for _, kv := range test.cmd.environ() {
+			for i := 0; i < len(kv); i++ {
+				if kv[i] == '=' {
+					got[kv[:i]] = kv[i+1:]
+					break
+				}
+			}
+		}
+		_, parent := got["VTE_GO_TEST_PARENT"]
+		if parent != test.parent {
+			t.Errorf("%s: parent variable kept = %v, want %v", test.name, parent, test.parent)
+		}
+		for k, v := range test.want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", test.name, k, got[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package vte
+
+import (
+	"context"
+	"sync"
+)
+
+// EventType identifies a terminal event.
+//
+type EventType int
+
+// Terminal events, one per terminal signal.
+const (
+	EventChildExited        EventType = iota // The child exited, see Status
+	EventContentsChanged                     // The visible content changed (coalesced)
+	EventCursorMoved                         // The cursor moved (coalesced)
+	EventWindowTitleChanged                  // The window title changed (coalesced)
+	EventBell                                // The child rang the bell
+	EventCommit                              // Data sent to the child, see Text
+	EventEOF                                 // The child output ended
+	EventSelectionChanged                    // The selection changed (coalesced)
+	EventCharSizeChanged                     // The character cell size changed, see Width and Height (coalesced)
+	EventIncreaseFontSize                    // The user asked for a bigger font
+	EventDecreaseFontSize                    // The user asked for a smaller font
+	EventOverflow                            // Events were dropped, see Count (coalesced)
+
+	EventCurrentDirectoryURIChanged // The current directory URI changed (coalesced)
+	EventCurrentFileURIChanged      // The current file URI changed (coalesced)
+	EventHoverURIChanged            // The hovered hyperlink changed, see Text and Bounds (coalesced)
+)
+
+var eventNames = map[EventType]string{
+	EventChildExited:        "child-exited",
+	EventContentsChanged:    "contents-changed",
+	EventCursorMoved:        "cursor-moved",
+	EventWindowTitleChanged: "window-title-changed",
+	EventBell:               "bell",
+	EventCommit:             "commit",
+	EventEOF:                "eof",
+	EventSelectionChanged:   "selection-changed",
+	EventCharSizeChanged:    "char-size-changed",
+	EventIncreaseFontSize:   "increase-font-size",
+	EventDecreaseFontSize:   "decrease-font-size",
+	EventOverflow:           "overflow",
+
+	EventCurrentDirectoryURIChanged: "current-directory-uri-changed",
+	EventCurrentFileURIChanged:      "current-file-uri-changed",
+	EventHoverURIChanged:            "hover-uri-changed",
+}
+
+// String returns the name of the signal of the event.
+//
+func (t EventType) String() string {
+	if name, ok := eventNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// coalesced returns whether consecutive events of the type are merged while
+// waiting for the consumer.
+//
+func (t EventType) coalesced() bool {
+	switch t {
+	case EventContentsChanged, EventCursorMoved, EventWindowTitleChanged,
+		EventSelectionChanged, EventCharSizeChanged, EventOverflow,
+		EventCurrentDirectoryURIChanged, EventCurrentFileURIChanged, EventHoverURIChanged:
+		return true
+	}
+	return false
+}
+
+// Event is a terminal event. Only the fields of its type are set.
+//
+type Event struct {
+	Type   EventType
+	Status ExitStatus // Status is the exit status of the child.
+	Text   string     // Text is the data sent to the child, or the hovered URI.
+	Width  int        // Width is the new character cell width, in pixels.
+	Height int        // Height is the new character cell height, in pixels.
+	Bounds Rectangle  // Bounds is the hovered hyperlink area, in pixels.
+	Count  int        // Count is the number of signals merged, or of events dropped for EventOverflow.
+}
+
+// EventPolicy defines which events are dropped when the consumer falls behind.
+// Dropped events are reported by an EventOverflow event.
+//
+type EventPolicy int
+
+// Event buffering policies.
+const (
+	EventsDropOldest EventPolicy = iota // Drop the oldest queued events, to keep the latest
+	EventsDropNewest                    // Drop new events until the queue has room
+	EventsUnbounded                     // Never drop events, the queue grows as needed
+)
+
+// EventOptions defines how events are buffered for a slow consumer.
+//
+type EventOptions struct {
+	Buffer int         // Buffer is the number of queued events before the policy applies (default 64).
+	Policy EventPolicy // Policy defines which events are dropped when the buffer is full.
+}
+
+// defaultEventBuffer is the event queue size when unset.
+//
+const defaultEventBuffer = 64
+
+// Events returns a channel receiving the terminal events, until the context is
+// done or the terminal destroyed. Events are queued with the default options.
+// It must be called from the GTK main loop.
+//
+func (v *Terminal) Events(ctx context.Context) <-chan Event {
+	return v.EventsWithOptions(ctx, EventOptions{})
+}
+
+// EventsWithOptions returns a channel receiving the terminal events, like
+// Events, with the given buffering.
+//
+// The signals are queued from the GTK main loop, which never waits for the
+// consumer: bursty signals are coalesced while pending, and the policy applies
+// when the queue is full.
+//
+func (v *Terminal) EventsWithOptions(ctx context.Context, opts EventOptions) <-chan Event {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultEventBuffer
+	}
+	q := &eventQueue{
+		term:    v,
+		out:     make(chan Event),
+		opts:    opts,
+		pending: make(map[EventType]*Event),
+		ready:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		st:      v.state(),
+	}
+
+	q.st.mu.Lock()
+	q.st.events = append(q.st.events, q)
+	q.st.mu.Unlock()
+
+	q.handles = []*SignalHandle{
+		v.OnChildExited(func(status ExitStatus) { q.push(Event{Type: EventChildExited, Status: status}) }),
+		v.OnContentsChanged(func() { q.push(Event{Type: EventContentsChanged}) }),
+		v.OnCursorMoved(func() { q.push(Event{Type: EventCursorMoved}) }),
+		v.OnWindowTitleChanged(func() { q.push(Event{Type: EventWindowTitleChanged}) }),
+		v.OnBell(func() { q.push(Event{Type: EventBell}) }),
+		v.OnCommit(func(text string) { q.push(Event{Type: EventCommit, Text: text}) }),
+		v.OnEOF(func() { q.push(Event{Type: EventEOF}) }),
+		v.OnSelectionChanged(func() { q.push(Event{Type: EventSelectionChanged}) }),
+		v.OnCharSizeChanged(func(w, h int) { q.push(Event{Type: EventCharSizeChanged, Width: w, Height: h}) }),
+		v.OnIncreaseFontSize(func() { q.push(Event{Type: EventIncreaseFontSize}) }),
+		v.OnDecreaseFontSize(func() { q.push(Event{Type: EventDecreaseFontSize}) }),
+		v.OnCurrentDirectoryURIChanged(func() { q.push(Event{Type: EventCurrentDirectoryURIChanged}) }),
+		v.OnCurrentFileURIChanged(func() { q.push(Event{Type: EventCurrentFileURIChanged}) }),
+		v.OnHoverURIChanged(func(uri string, bbox Rectangle) { q.push(Event{Type: EventHoverURIChanged, Text: uri, Bounds: bbox}) }),
+	}
+
+	go q.run(ctx)
+	return q.out
+}
+
+// eventQueue buffers the events of an Events channel between the GTK main
+// loop and the consumer.
+//
+type eventQueue struct {
+	term    *Terminal
+	out     chan Event
+	opts    EventOptions
+	st      *termState
+	handles []*SignalHandle // Main loop only.
+
+	mu       sync.Mutex
+	queue    []*Event
+	pending  map[EventType]*Event // Queued coalesced events, by type.
+	ready    chan struct{}        // Wakes the consumer goroutine.
+	stop     chan struct{}        // Closed when the terminal is destroyed.
+	closed   bool                 // The consumer goroutine ended.
+	released bool                 // The stop channel is closed.
+}
+
+// push queues the event, called from the GTK main loop. The hooks are
+// disconnected once the channel is closed.
+//
+func (q *eventQueue) push(ev Event) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.disconnect()
+		return
+	}
+
+	ev.Count = 1
+	if prev, ok := q.pending[ev.Type]; ok && ev.Type.coalesced() {
+		prev.Text, prev.Width, prev.Height, prev.Bounds = ev.Text, ev.Width, ev.Height, ev.Bounds
+		prev.Count++
+	} else {
+		q.add(&ev)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// add queues a new event, applying the policy if the queue is full.
+// Called with the lock held.
+//
+func (q *eventQueue) add(ev *Event) {
+	if q.opts.Policy != EventsUnbounded && len(q.queue) >= q.opts.Buffer {
+		if q.opts.Policy == EventsDropNewest {
+			q.overflow(1)
+			return
+		}
+		q.overflow(q.dropFirst())
+	}
+
+	q.queue = append(q.queue, ev)
+	if ev.Type.coalesced() {
+		q.pending[ev.Type] = ev
+	}
+}
+
+// dropFirst removes the oldest event other than the overflow one, and returns
+// the number of events dropped.
+//
+func (q *eventQueue) dropFirst() int {
+	for i, ev := range q.queue {
+		if ev.Type == EventOverflow {
+			continue
+		}
+		q.queue = append(q.queue[:i:i], q.queue[i+1:]...)
+		if q.pending[ev.Type] == ev {
+			delete(q.pending, ev.Type)
+		}
+		if ev.Type.coalesced() {
+			return ev.Count
+		}
+		return 1
+	}
+	return 0
+}
+
+// overflow counts dropped events in the pending overflow event, so a single one
+// is queued until the consumer gets it.
+//
+func (q *eventQueue) overflow(count int) {
+	if count == 0 {
+		return
+	}
+	if prev, ok := q.pending[EventOverflow]; ok {
+		prev.Count += count
+		return
+	}
+	ev := &Event{Type: EventOverflow, Count: count}
+	q.queue = append(q.queue, ev)
+	q.pending[EventOverflow] = ev
+}
+
+// next pops the oldest queued event, or returns nil if there's none.
+//
+func (q *eventQueue) next() *Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.queue) == 0 {
+		return nil
+	}
+	ev := q.queue[0]
+	q.queue = q.queue[1:]
+	if q.pending[ev.Type] == ev {
+		delete(q.pending, ev.Type)
+	}
+	return ev
+}
+
+// run delivers the queued events to the consumer until the context is done or
+// the terminal destroyed.
+//
+func (q *eventQueue) run(ctx context.Context) {
+	defer func() {
+		q.mu.Lock()
+		q.closed = true
+		q.queue = nil
+		released := q.released
+		q.mu.Unlock()
+		q.st.removeEvents(q)
+		close(q.out)
+		if !released { // Some signals may never fire again to disconnect.
+			q.term.idleAddAlive(func() bool { q.disconnect(); return false })
+		}
+	}()
+
+	for {
+		ev := q.next()
+		if ev == nil {
+			select {
+			case <-q.ready:
+				continue
+			case <-q.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case q.out <- *ev:
+		case <-q.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// disconnect disconnects the signal hooks, from the GTK main loop.
+//
+func (q *eventQueue) disconnect() {
+	for _, h := range q.handles {
+		h.Disconnect()
+	}
+	q.handles = nil
+}
+
+// release ends the channel when the terminal is destroyed.
+//
+func (q *eventQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.released {
+		q.released = true
+		close(q.stop)
+	}
+}
+
+// removeEvents forgets the event queue of a closed channel.
+//
+func (st *termState) removeEvents(q *eventQueue) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	kept := st.events[:0:0]
+	for _, other := range st.events {
+		if other != q {
+			kept = append(kept, other)
+		}
+	}
+	st.events = kept
+}
@@ -0,0 +1,111 @@
+package vte
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestQueue returns an event queue without terminal nor consumer.
+//
+func newTestQueue(opts EventOptions) *eventQueue {
+	return &eventQueue{
+		opts:    opts,
+		pending: make(map[EventType]*Event),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// drain pops all the queued events.
+//
+func (q *eventQueue) drain() []Event {
+	var list []Event
+	for ev := q.next(); ev != nil; ev = q.next() {
+		list = append(list, *ev)
+	}
+	return list
+}
+
+func TestEventQueue(t *testing.T) {
+	bell := Event{Type: EventBell}
+	commit := func(text string) Event { return Event{Type: EventCommit, Text: text} }
+	size := func(w, h int) Event { return Event{Type: EventCharSizeChanged, Width: w, Height: h} }
+	contents := Event{Type: EventContentsChanged}
+
+	tests := []struct {
+		name   string
+		opts   EventOptions
+		events []Event
+		want   []Event
+	}{
+		{"in order", EventOptions{Buffer: 8},
+			[]Event{bell, commit("a"), contents},
+			[]Event{{Type: EventBell, Count: 1}, {Type: EventCommit, Text: "a", Count: 1}, {Type: EventContentsChanged, Count: 1}}},
+
+		{"coalesced", EventOptions{Buffer: 8},
+			[]Event{contents, bell, contents, contents},
+			[]Event{{Type: EventContentsChanged, Count: 3}, {Type: EventBell, Count: 1}}},
+
+		{"coalesced keep the last values", EventOptions{Buffer: 8},
+			[]Event{size(8, 16), size(9, 18)},
+			[]Event{{Type: EventCharSizeChanged, Width: 9, Height: 18, Count: 2}}},
+
+		{"not coalesced", EventOptions{Buffer: 8},
+			[]Event{bell, bell, commit("a"), commit("b")},
+			[]Event{{Type: EventBell, Count: 1}, {Type: EventBell, Count: 1}, {Type: EventCommit, Text: "a", Count: 1}, {Type: EventCommit, Text: "b", Count: 1}}},
+
+		{"drop newest", EventOptions{Buffer: 2, Policy: EventsDropNewest},
+			[]Event{commit("a"), commit("b"), commit("c"), commit("d")},
+			[]Event{{Type: EventCommit, Text: "a", Count: 1}, {Type: EventCommit, Text: "b", Count: 1}, {Type: EventOverflow, Count: 2}}},
+
+		{"drop newest coalesces", EventOptions{Buffer: 2, Policy: EventsDropNewest},
+			[]Event{commit("a"), contents, contents, bell},
+			[]Event{{Type: EventCommit, Text: "a", Count: 1}, {Type: EventContentsChanged, Count: 2}, {Type: EventOverflow, Count: 1}}},
+
+		{"drop oldest", EventOptions{Buffer: 2, Policy: EventsDropOldest},
+			[]Event{commit("a"), commit("b"), commit("c"), commit("d")},
+			[]Event{{Type: EventOverflow, Count: 2}, {Type: EventCommit, Text: "c", Count: 1}, {Type: EventCommit, Text: "d", Count: 1}}},
+
+		{"drop oldest counts coalesced", EventOptions{Buffer: 2, Policy: EventsDropOldest},
+			[]Event{contents, contents, contents, bell, commit("a")},
+			[]Event{{Type: EventBell, Count: 1}, {Type: EventOverflow, Count: 3}, {Type: EventCommit, Text: "a", Count: 1}}},
+
+		{"unbounded", EventOptions{Buffer: 1, Policy: EventsUnbounded},
+			[]Event{commit("a"), commit("b"), commit("c")},
+			[]Event{{Type: EventCommit, Text: "a", Count: 1}, {Type: EventCommit, Text: "b", Count: 1}, {Type: EventCommit, Text: "c", Count: 1}}},
+	}
+	for _, test := range tests {
+		q := newTestQueue(test.opts)
+		for _, ev := range test.events {
+			q.push(ev)
+		}
+		got := q.drain()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEventQueueAfterNext(t *testing.T) {
+	q := newTestQueue(EventOptions{Buffer: 8})
+	q.push(Event{Type: EventCursorMoved})
+	q.push(Event{Type: EventCursorMoved})
+	if ev := q.next(); ev == nil || ev.Count != 2 {
+		t.Fatalf("first event = %+v, want 2 merged", ev)
+	}
+
+	q.push(Event{Type: EventCursorMoved}) // Not merged in the delivered one.
+	got := q.drain()
+	want := []Event{{Type: EventCursorMoved, Count: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestEventQueueClosed(t *testing.T) {
+	q := newTestQueue(EventOptions{Buffer: 8})
+	q.closed = true
+	q.push(Event{Type: EventBell})
+	if got := q.drain(); got != nil {
+		t.Errorf("closed queue got %+v", got)
+	}
+}
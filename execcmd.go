@@ -0,0 +1,53 @@
+package vte
+
+// #include <vte/vte.h>
+import "C"
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// AttachExecCmd prepares an os/exec command to run on a new PTY of the terminal.
+//
+// The PTY slave is set as the command standard input, output and error, and
+// the command is started in a new session with the PTY as controlling terminal,
+// so it behaves as if Vte had spawned it. TERM and VTE_VERSION are added to
+// its environment.
+//
+// The returned tty is the PTY slave. Close it once cmd.Start returned, so the
+// terminal gets the end of the stream when the command exits.
+//
+// The terminal doesn't watch the command, use cmd.Wait to get its exit status.
+//
+func (v *Terminal) AttachExecCmd(cmd *exec.Cmd) (tty *os.File, e error) {
+	pty, e := v.PtyNew(PtyDefault)
+	if e != nil {
+		return nil, e
+	}
+	defer pty.Close() // The terminal keeps its own reference.
+
+	tty, e = pty.SetupExecCmd(cmd)
+	if e != nil {
+		return nil, e
+	}
+	v.SetPty(pty)
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = mergeEnv(append(env[:len(env):len(env)], "TERM=xterm-256color", "VTE_VERSION="+vteVersion()), nil)
+	return tty, nil
+}
+
+// vteVersion returns the runtime Vte version formatted like the VTE_VERSION
+// variable set by Vte: 5200 for 0.52.0.
+//
+func vteVersion() string {
+	major := int(C.vte_get_major_version())
+	minor := int(C.vte_get_minor_version())
+	micro := int(C.vte_get_micro_version())
+	return strconv.Itoa(major*10000 + minor*100 + micro)
+}
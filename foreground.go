@@ -0,0 +1,98 @@
+package vte
+
+// #include <unistd.h>
+import "C"
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrNoPty is returned when the terminal has no PTY.
+//
+var ErrNoPty = errors.New("terminal has no PTY")
+
+// ErrNoForeground is returned when the terminal PTY has no foreground process
+// group, like once the shell exited or when spawned with PtyNoCtty.
+//
+var ErrNoForeground = errors.New("terminal has no foreground process")
+
+// ProcessInfo describes a running process.
+//
+type ProcessInfo struct {
+	Pid  int      // Pid is the process id.
+	Args []string // Args holds the command line arguments.
+	Exe  string   // Exe is the path of the executable, if readable.
+	Dir  string   // Dir is the working directory, if readable.
+}
+
+// ForegroundProcess returns the leader of the foreground process group of the
+// terminal PTY. It's the shell itself when nothing else is running.
+//
+// The process is read from /proc, so it's only supported on Linux. Returns
+// ErrNoForeground if the PTY has no foreground process group.
+//
+func (v *Terminal) ForegroundProcess() (*ProcessInfo, error) {
+	pgrp, e := v.foregroundGroup()
+	if e != nil {
+		return nil, e
+	}
+	return readProcessInfo(pgrp)
+}
+
+// SignalForeground sends the signal to the foreground process group of the
+// terminal PTY, like the tty driver does for ^C (SIGINT) or ^Z (SIGTSTP).
+// Returns ErrNoForeground if the PTY has no foreground process group.
+//
+func (v *Terminal) SignalForeground(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("unsupported signal type")
+	}
+	pgrp, e := v.foregroundGroup()
+	if e != nil {
+		return e
+	}
+	return syscall.Kill(-pgrp, s)
+}
+
+// foregroundGroup returns the foreground process group id of the terminal PTY.
+// A PTY without foreground group gives 0, which must never reach kill.
+//
+func (v *Terminal) foregroundGroup() (int, error) {
+	pty := v.commandPty()
+	if pty == nil {
+		return 0, ErrNoPty
+	}
+	defer pty.Close()
+
+	pgrp, e := C.tcgetpgrp(C.int(pty.Fd()))
+	if pgrp < 0 {
+		return 0, os.NewSyscallError("tcgetpgrp", e)
+	}
+	if pgrp == 0 {
+		return 0, ErrNoForeground
+	}
+	return int(pgrp), nil
+}
+
+// readProcessInfo reads the process information in /proc.
+//
+func readProcessInfo(pid int) (*ProcessInfo, error) {
+	dir := "/proc/" + strconv.Itoa(pid)
+	cmdline, e := os.ReadFile(dir + "/cmdline")
+	if e != nil {
+		return nil, e
+	}
+
+	info := &ProcessInfo{Pid: pid}
+	if args := strings.TrimSuffix(string(cmdline), "\x00"); args != "" {
+		info.Args = strings.Split(args, "\x00")
+	}
+	info.Exe, _ = os.Readlink(dir + "/exe") // Only readable for our own processes.
+	info.Dir, _ = os.Readlink(dir + "/cwd")
+	return info, nil
+}
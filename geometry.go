@@ -0,0 +1,122 @@
+package vte
+
+/*
+#include <vte/vte.h>
+
+// Go exported func redeclarations.
+extern void onGeometryChanged (VteTerminal *terminal);
+
+
+static inline void onCharSizeChanged(VteTerminal *terminal, guint width, guint height, gpointer data) {
+	onGeometryChanged(terminal);
+}
+
+static inline void onSizeAllocate(GtkWidget *widget, GdkRectangle *allocation, gpointer data) {
+	onGeometryChanged(VTE_TERMINAL(widget));
+}
+
+static inline void connectGeometry(VteTerminal *terminal) {
+	g_signal_connect(terminal, "char-size-changed", G_CALLBACK(onCharSizeChanged), NULL);
+	g_signal_connect_after(terminal, "size-allocate", G_CALLBACK(onSizeAllocate), NULL);
+}
+*/
+import "C"
+
+// GetRowCount returns the number of visible rows of the terminal.
+//
+func (v *Terminal) GetRowCount() int {
+	return int(C.vte_terminal_get_row_count(v.Native()))
+}
+
+// GetColumnCount returns the number of columns of the terminal.
+//
+func (v *Terminal) GetColumnCount() int {
+	return int(C.vte_terminal_get_column_count(v.Native()))
+}
+
+// GetCharWidth returns the width of a character cell, in pixels.
+//
+func (v *Terminal) GetCharWidth() int {
+	return int(C.vte_terminal_get_char_width(v.Native()))
+}
+
+// GetCharHeight returns the height of a character cell, in pixels.
+//
+func (v *Terminal) GetCharHeight() int {
+	return int(C.vte_terminal_get_char_height(v.Native()))
+}
+
+// SetSize attempts to change the terminal's size in columns and rows. The
+// widget size request is updated, the final size depends on its container.
+//
+func (v *Terminal) SetSize(cols, rows int) {
+	C.vte_terminal_set_size(v.Native(), C.glong(cols), C.glong(rows))
+}
+
+// OnResize connects a hook called with the new grid size when the number of
+// columns or rows of the terminal changes, after a widget resize or a font
+// change.
+//
+func (v *Terminal) OnResize(call func(cols, rows int)) *SignalHandle {
+	v.watchGeometry()
+	st := v.state()
+	hook := &call
+	st.mu.Lock()
+	st.resize = append(st.resize, hook)
+	st.mu.Unlock()
+
+	return &SignalHandle{terminal: v.ptr, remove: func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		kept := make([]*func(cols, rows int), 0, len(st.resize))
+		for _, h := range st.resize {
+			if h != hook {
+				kept = append(kept, h)
+			}
+		}
+		st.resize = kept
+	}}
+}
+
+// watchGeometry connects the signals changing the terminal grid, once.
+//
+func (v *Terminal) watchGeometry() {
+	st := v.state()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.geometry {
+		st.geometry = true
+		st.cols, st.rows = v.GetColumnCount(), v.GetRowCount()
+		C.connectGeometry(v.Native())
+	}
+}
+
+//export onGeometryChanged
+//
+// called when the terminal character size or allocation changed.
+//
+func onGeometryChanged(terminal *C.VteTerminal) {
+	st := findState(terminal)
+	if st == nil {
+		return
+	}
+	cols := int(C.vte_terminal_get_column_count(terminal))
+	rows := int(C.vte_terminal_get_row_count(terminal))
+
+	st.mu.Lock()
+	if cols == st.cols && rows == st.rows {
+		st.mu.Unlock()
+		return
+	}
+	st.cols, st.rows = cols, rows
+	strm := st.stream
+	hooks := st.resize
+	st.mu.Unlock()
+
+	if strm != nil {
+		strm.pty.SetSize(rows, cols) // Vte only resizes its own PTY.
+	}
+	for _, call := range hooks {
+		(*call)(cols, rows)
+	}
+}
@@ -0,0 +1,83 @@
+package vte
+
+/*
+#include <vte/vte.h>
+
+static gboolean setAllowHyperlink (VteTerminal *terminal, gboolean allow) {
+#if VTE_CHECK_VERSION(0, 50, 0)
+	vte_terminal_set_allow_hyperlink(terminal, allow);
+	return TRUE;
+#else
+	return FALSE;
+#endif
+}
+
+static gboolean getAllowHyperlink (VteTerminal *terminal) {
+#if VTE_CHECK_VERSION(0, 50, 0)
+	return vte_terminal_get_allow_hyperlink(terminal);
+#else
+	return FALSE;
+#endif
+}
+
+// hyperlinkAt sets uri to the hyperlink at the center of the visible cell.
+// Returns FALSE if unsupported (Vte < 0.70).
+static gboolean hyperlinkAt (VteTerminal *terminal, glong col, glong row, char **uri) {
+#if VTE_CHECK_VERSION(0, 70, 0)
+	GtkBorder padding;
+	GtkStyleContext *context = gtk_widget_get_style_context(GTK_WIDGET(terminal));
+	gtk_style_context_get_padding(context, gtk_style_context_get_state(context), &padding);
+
+	double x = padding.left + (col + 0.5) * vte_terminal_get_char_width(terminal);
+	double y = padding.top + (row + 0.5) * vte_terminal_get_char_height(terminal);
+	*uri = vte_terminal_check_hyperlink_at(terminal, x, y);
+	return TRUE;
+#else
+	return FALSE;
+#endif
+}
+*/
+import "C"
+
+import "unsafe"
+
+// Rectangle is an area of the terminal widget, in pixels.
+//
+type Rectangle struct {
+	X, Y          int
+	Width, Height int
+}
+
+// SetAllowHyperlink sets whether the child can display OSC 8 hyperlinks.
+// Returns ErrUnsupported before Vte 0.50.
+//
+func (v *Terminal) SetAllowHyperlink(allow bool) error {
+	if C.setAllowHyperlink(v.Native(), cbool(allow)) == 0 {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// GetAllowHyperlink returns whether the child can display OSC 8 hyperlinks.
+//
+func (v *Terminal) GetAllowHyperlink() bool {
+	return C.getAllowHyperlink(v.Native()) != 0
+}
+
+// HyperlinkAtPosition returns the URI of the hyperlink in the cell, or an
+// empty string if there is none. The row is counted from the top of the
+// visible area.
+//
+// Returns ErrUnsupported before Vte 0.70.
+//
+func (v *Terminal) HyperlinkAtPosition(col, row int) (string, error) {
+	var c *C.char
+	if C.hyperlinkAt(v.Native(), C.glong(col), C.glong(row), &c) == 0 {
+		return "", ErrUnsupported
+	}
+	if c == nil {
+		return "", nil
+	}
+	defer C.g_free(C.gpointer(unsafe.Pointer(c)))
+	return C.GoString(c), nil
+}
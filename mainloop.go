@@ -0,0 +1,110 @@
+package vte
+
+/*
+#include <glib.h>
+
+// Go exported func redeclarations.
+extern gboolean onSourceCall (gpointer data);
+extern void     onSourceDestroy (gpointer data);
+
+
+static inline guint timeoutAdd(guint ms, gpointer data) {
+	return g_timeout_add_full(G_PRIORITY_DEFAULT, ms, onSourceCall, data, onSourceDestroy);
+}
+
+static inline guint idleAdd(gpointer data) {
+	return g_idle_add_full(G_PRIORITY_DEFAULT_IDLE, onSourceCall, data, onSourceDestroy);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"time"
+)
+
+// timeoutAdd calls f in the GTK main loop after the delay, and again at the
+// same interval while it returns true. Safe to use from any goroutine.
+//
+// The source is dropped with the terminal.
+//
+func (v *Terminal) timeoutAdd(delay time.Duration, f func() bool) uint {
+	return uint(C.timeoutAdd(C.guint(delay/time.Millisecond), v.newCallback("source", f)))
+}
+
+// idleAdd calls f in the GTK main loop when idle, and again while it returns
+// true. Safe to use from any goroutine.
+//
+// The source is dropped with the terminal.
+//
+func (v *Terminal) idleAdd(f func() bool) uint {
+	return uint(C.idleAdd(v.newCallback("source", f)))
+}
+
+// idleAddAlive is like idleAdd, for goroutines which can outlive the terminal:
+// nothing is scheduled once the terminal is finalized.
+//
+func (v *Terminal) idleAddAlive(f func() bool) {
+	terminalStatesMU.Lock() // Held so the finalize can't run in between.
+	defer terminalStatesMU.Unlock()
+	if terminalStates[v.ptr] == nil {
+		return
+	}
+	C.idleAdd(registerCallback(v.ptr, "source", f))
+}
+
+// sourceRemove removes a source added with timeoutAdd or idleAdd.
+//
+func sourceRemove(id uint) {
+	if id > 0 {
+		C.g_source_remove(C.guint(id))
+	}
+}
+
+// iterateUntil runs iterations of the default GLib main context until done is
+// closed or the context ends. It must be called from the GTK main loop thread.
+//
+func iterateUntil(ctx context.Context, done <-chan struct{}) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { // Wakes the main context for events coming from other threads.
+		select {
+		case <-done:
+		case <-ctx.Done():
+		case <-stop:
+			return
+		}
+		C.g_main_context_wakeup(nil)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		C.g_main_context_iteration(nil, C.TRUE)
+	}
+}
+
+//export onSourceCall
+//
+// called by the main loop for sources added with timeoutAdd or idleAdd.
+//
+func onSourceCall(data C.gpointer) C.gboolean {
+	f, ok := lookupCallback(data).(func() bool)
+	if !ok {
+		return C.FALSE // Released with the terminal.
+	}
+	return cbool(f())
+}
+
+//export onSourceDestroy
+//
+// called when a source added with timeoutAdd or idleAdd is removed.
+//
+func onSourceDestroy(data C.gpointer) {
+	takeCallback(data)
+}
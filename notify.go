@@ -0,0 +1,149 @@
+package vte
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ProgressState is the state of a progress reported by the child with the
+// OSC 9;4 sequence.
+//
+type ProgressState int
+
+// Progress states.
+const (
+	ProgressNone          ProgressState = iota // The progress is removed
+	ProgressNormal                             // Running, see the percent
+	ProgressError                              // Failed, see the percent
+	ProgressIndeterminate                      // Running, the percent is unknown
+	ProgressPaused                             // Paused or warning, see the percent
+)
+
+// OnNotification connects a hook called when the child asks for a desktop
+// notification, with OSC 777;notify;title;body or OSC 9;body (no title).
+//
+// Like output taps, the sequences are read by relaying the PTY of the child,
+// for commands started after the first hook was added (Vte 0.48+), until all
+// hooks are disconnected.
+//
+func (v *Terminal) OnNotification(call func(title, body string)) *SignalHandle {
+	st := v.state()
+	hook := &call
+	st.mu.Lock()
+	st.notification = append(st.notification, hook)
+	st.mu.Unlock()
+
+	return &SignalHandle{terminal: v.ptr, remove: func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		kept := make([]*func(title, body string), 0, len(st.notification))
+		for _, h := range st.notification {
+			if h != hook {
+				kept = append(kept, h)
+			}
+		}
+		st.notification = kept
+	}}
+}
+
+// OnProgress connects a hook called when the child reports a progress with
+// OSC 9;4;state;percent. The percent is between 0 and 100.
+//
+// Like output taps, the sequences are read by relaying the PTY of the child,
+// for commands started after the first hook was added (Vte 0.48+), until all
+// hooks are disconnected.
+//
+func (v *Terminal) OnProgress(call func(state ProgressState, percent int)) *SignalHandle {
+	st := v.state()
+	hook := &call
+	st.mu.Lock()
+	st.progress = append(st.progress, hook)
+	st.mu.Unlock()
+
+	return &SignalHandle{terminal: v.ptr, remove: func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		kept := make([]*func(state ProgressState, percent int), 0, len(st.progress))
+		for _, h := range st.progress {
+			if h != hook {
+				kept = append(kept, h)
+			}
+		}
+		st.progress = kept
+	}}
+}
+
+// handleOSC calls the hooks of the notification and progress sequences.
+//
+func (st *termState) handleOSC(payload []byte) {
+	st.mu.Lock()
+	notification := st.notification
+	progress := st.progress
+	st.mu.Unlock()
+
+	if state, percent, ok := parseProgress(payload); ok {
+		for _, call := range progress {
+			(*call)(state, percent)
+		}
+		return
+	}
+	if title, body, ok := parseNotification(payload); ok {
+		for _, call := range notification {
+			(*call)(title, body)
+		}
+	}
+}
+
+// parseNotification parses an OSC 777;notify;title;body or OSC 9;body payload.
+//
+func parseNotification(payload []byte) (title, body string, ok bool) {
+	text := string(payload)
+	switch {
+	case strings.HasPrefix(text, "777;notify;"):
+		fields := strings.SplitN(strings.TrimPrefix(text, "777;notify;"), ";", 2)
+		if len(fields) == 2 {
+			body = fields[1]
+		}
+		return fields[0], body, true
+
+	case strings.HasPrefix(text, "9;"):
+		body = strings.TrimPrefix(text, "9;")
+		if isConEmuCommand(body) {
+			return "", "", false
+		}
+		return "", body, true
+	}
+	return "", "", false
+}
+
+// isConEmuCommand returns whether the OSC 9 text is a ConEmu command, like
+// 4;state;percent, and not a notification.
+//
+func isConEmuCommand(text string) bool {
+	code := strings.SplitN(text, ";", 2)[0]
+	n, e := strconv.Atoi(code)
+	return e == nil && n >= 1 && n <= 12
+}
+
+// parseProgress parses an OSC 9;4;state;percent payload.
+//
+func parseProgress(payload []byte) (state ProgressState, percent int, ok bool) {
+	fields := strings.Split(string(payload), ";")
+	if len(fields) < 3 || fields[0] != "9" || fields[1] != "4" {
+		return 0, 0, false
+	}
+	n, e := strconv.Atoi(fields[2])
+	if e != nil || n < int(ProgressNone) || n > int(ProgressPaused) {
+		return 0, 0, false
+	}
+	if len(fields) > 3 {
+		percent, _ = strconv.Atoi(fields[3])
+	}
+	switch {
+	case percent < 0:
+		percent = 0
+	case percent > 100:
+		percent = 100
+	}
+	return ProgressState(n), percent, true
+}
@@ -0,0 +1,63 @@
+package vte
+
+import "testing"
+
+func TestParseNotification(t *testing.T) {
+	tests := []struct {
+		payload     string
+		title, body string
+		ok          bool
+	}{
+		{"777;notify;Build;done", "Build", "done", true},
+		{"777;notify;Build;a;b", "Build", "a;b", true},
+		{"777;notify;Build", "Build", "", true},
+		{"777;notify;;body", "", "body", true},
+		{"9;Build done", "", "Build done", true},
+		{"9;", "", "", true},
+		{"9;13;text", "", "13;text", true},
+		{"9;4;1;50", "", "", false}, // Progress.
+		{"9;1;100", "", "", false},  // ConEmu sleep.
+		{"9;12", "", "", false},
+		{"777;other;x", "", "", false},
+		{"0;title", "", "", false},
+		{"9", "", "", false},
+	}
+	for _, test := range tests {
+		title, body, ok := parseNotification([]byte(test.payload))
+		if title != test.title || body != test.body || ok != test.ok {
+			t.Errorf("parseNotification(%q) = %q, %q, %v, want %q, %q, %v",
+				test.payload, title, body, ok, test.title, test.body, test.ok)
+		}
+	}
+}
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		payload string
+		state   ProgressState
+		percent int
+		ok      bool
+	}{
+		{"9;4;0", ProgressNone, 0, true},
+		{"9;4;1;42", ProgressNormal, 42, true},
+		{"9;4;2;100", ProgressError, 100, true},
+		{"9;4;3", ProgressIndeterminate, 0, true},
+		{"9;4;4;7", ProgressPaused, 7, true},
+		{"9;4;1;150", ProgressNormal, 100, true},
+		{"9;4;1;-5", ProgressNormal, 0, true},
+		{"9;4;1;x", ProgressNormal, 0, true},
+		{"9;4;5;10", 0, 0, false},
+		{"9;4;-1", 0, 0, false},
+		{"9;4;x", 0, 0, false},
+		{"9;4", 0, 0, false},
+		{"9;5;1", 0, 0, false},
+		{"777;notify;a;b", 0, 0, false},
+	}
+	for _, test := range tests {
+		state, percent, ok := parseProgress([]byte(test.payload))
+		if state != test.state || percent != test.percent || ok != test.ok {
+			t.Errorf("parseProgress(%q) = %v, %d, %v, want %v, %d, %v",
+				test.payload, state, percent, ok, test.state, test.percent, test.ok)
+		}
+	}
+}
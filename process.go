@@ -0,0 +1,173 @@
+package vte
+
+/*
+#include <errno.h>
+#include <sys/wait.h>
+
+// childReaped returns whether the pid is no longer a child waiting to be
+// reaped, without reaping it.
+static int childReaped (pid_t pid) {
+	siginfo_t info;
+	return waitid(P_PID, pid, &info, WEXITED | WNOHANG | WNOWAIT) != 0 && errno == ECHILD;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ExitStatus is the wait status of an exited child process.
+//
+type ExitStatus int
+
+// Exited reports whether the process exited normally.
+//
+func (s ExitStatus) Exited() bool {
+	return syscall.WaitStatus(s).Exited()
+}
+
+// ExitCode returns the exit code of the process, or -1 if it didn't exit
+// normally.
+//
+func (s ExitStatus) ExitCode() int {
+	return syscall.WaitStatus(s).ExitStatus()
+}
+
+// Signaled reports whether the process was terminated by a signal.
+//
+func (s ExitStatus) Signaled() bool {
+	return syscall.WaitStatus(s).Signaled()
+}
+
+// Signal returns the signal that terminated the process, or -1 if it wasn't
+// terminated by a signal.
+//
+func (s ExitStatus) Signal() syscall.Signal {
+	return syscall.WaitStatus(s).Signal()
+}
+
+// CoreDumped reports whether the process dumped core when terminated.
+//
+func (s ExitStatus) CoreDumped() bool {
+	return syscall.WaitStatus(s).CoreDump()
+}
+
+// String returns a readable status, like "exit status 1" or
+// "signal: segmentation fault (core dumped)".
+//
+func (s ExitStatus) String() string {
+	switch {
+	case s.Exited():
+		return "exit status " + strconv.Itoa(s.ExitCode())
+
+	case s.Signaled() && s.CoreDumped():
+		return "signal: " + s.Signal().String() + " (core dumped)"
+
+	case s.Signaled():
+		return "signal: " + s.Signal().String()
+	}
+	return "unknown status " + strconv.Itoa(int(s))
+}
+
+// Process is a command started in a terminal.
+//
+// It's completed by the terminal "child-exited" signal, so the GTK main loop
+// must be running for Wait to return. Never call Wait from the main loop.
+//
+type Process struct {
+	mu     sync.Mutex
+	pid    int
+	status ExitStatus
+	err    error
+	done   chan struct{}
+}
+
+func newProcess() *Process {
+	return &Process{done: make(chan struct{})}
+}
+
+// Pid returns the process id, or 0 if it isn't started yet or failed to.
+//
+func (p *Process) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pid
+}
+
+// Done returns a channel closed when the process has exited or failed to start.
+//
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the process to exit and returns its exit status.
+// The error is set when the process could not be started.
+//
+func (p *Process) Wait() (ExitStatus, error) {
+	<-p.done
+	return p.status, p.err
+}
+
+// Signal sends a signal to the process.
+//
+// GLib reaps the child before the terminal reports its exit, which Vte can
+// delay until the end of the output (a background job can keep the PTY open).
+// To avoid signaling another process reusing the pid in that window, the pid
+// is checked to still be our child, exited or not. Only a reuse between that
+// check and the signal, a few instructions, remains possible.
+//
+func (p *Process) Signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("unsupported signal type")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		return os.ErrProcessDone
+	default:
+	}
+	if p.pid == 0 {
+		return errors.New("process not started")
+	}
+	if C.childReaped(C.pid_t(p.pid)) != 0 { // Exit not reported yet.
+		return os.ErrProcessDone
+	}
+	return syscall.Kill(p.pid, s)
+}
+
+// Kill causes the process to exit immediately.
+//
+func (p *Process) Kill() error {
+	return p.Signal(os.Kill)
+}
+
+// start sets the pid of the started process.
+//
+func (p *Process) start(pid int) {
+	p.mu.Lock()
+	p.pid = pid
+	p.mu.Unlock()
+}
+
+// finish sets the result of the process and releases waiters.
+//
+func (p *Process) finish(status ExitStatus, e error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		return // Already finished.
+	default:
+	}
+	p.status = status
+	p.err = e
+	close(p.done)
+}
@@ -0,0 +1,35 @@
+package vte
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		status   ExitStatus
+		exited   bool
+		code     int
+		signaled bool
+		signal   syscall.Signal
+		core     bool
+		text     string
+	}{
+		{0, true, 0, false, -1, false, "exit status 0"},
+		{1 << 8, true, 1, false, -1, false, "exit status 1"},
+		{127 << 8, true, 127, false, -1, false, "exit status 127"},
+		{ExitStatus(syscall.SIGKILL), false, -1, true, syscall.SIGKILL, false, "signal: killed"},
+		{ExitStatus(syscall.SIGSEGV) | 0x80, false, -1, true, syscall.SIGSEGV, true, "signal: segmentation fault (core dumped)"},
+		{0x7f | ExitStatus(syscall.SIGSTOP)<<8, false, -1, false, -1, false, "unknown status 4991"}, // Stopped.
+	}
+	for _, test := range tests {
+		s := test.status
+		if s.Exited() != test.exited || s.ExitCode() != test.code ||
+			s.Signaled() != test.signaled || s.Signal() != test.signal ||
+			s.CoreDumped() != test.core || s.String() != test.text {
+			t.Errorf("status %#x: got exited=%v code=%d signaled=%v signal=%d core=%v %q, want %v %d %v %d %v %q",
+				int(s), s.Exited(), s.ExitCode(), s.Signaled(), s.Signal(), s.CoreDumped(), s.String(),
+				test.exited, test.code, test.signaled, test.signal, test.core, test.text)
+		}
+	}
+}
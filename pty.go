@@ -0,0 +1,194 @@
+package vte
+
+/*
+#define _GNU_SOURCE // ptsname
+#include <stdlib.h>
+#include <vte/vte.h>
+
+static char * ptsName(int fd) {
+	char *name = ptsname(fd);
+	return name ? g_strdup(name) : NULL;
+}
+
+static VtePty * refPty   (VtePty *pty) { return g_object_ref(pty); }
+static void     unrefPty (VtePty *pty) { g_object_unref(pty); }
+*/
+import "C"
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Pty is a representation of Vte's VtePty.
+//
+type Pty struct {
+	ptr *C.VtePty
+}
+
+// NewPty creates a new PTY. It's a wrapper around vte_pty_new_sync().
+//
+func NewPty(flags PtyFlags) (*Pty, error) {
+	var cerr *C.GError
+	c := C.vte_pty_new_sync(C.VtePtyFlags(flags), nil, &cerr)
+	if cerr != nil {
+		return nil, takeError(cerr)
+	}
+	return wrapPty(c), nil
+}
+
+// NewPtyFromFd creates a PTY for the given PTY master descriptor, which is
+// owned by the PTY from then. It's a wrapper around vte_pty_new_foreign_sync().
+//
+func NewPtyFromFd(fd int) (*Pty, error) {
+	var cerr *C.GError
+	c := C.vte_pty_new_foreign_sync(C.int(fd), nil, &cerr)
+	if cerr != nil {
+		return nil, takeError(cerr)
+	}
+	return wrapPty(c), nil
+}
+
+// wrapPty wraps the owned reference of the PTY, released by the garbage
+// collector.
+//
+func wrapPty(c *C.VtePty) *Pty {
+	p := &Pty{c}
+	runtime.SetFinalizer(p, (*Pty).Close)
+	return p
+}
+
+// ref returns a new reference on the PTY.
+//
+func (p *Pty) ref() *Pty {
+	return wrapPty(C.refPty(p.ptr))
+}
+
+// Native returns a pointer to the underlying VtePty.
+//
+func (p *Pty) Native() *C.VtePty {
+	return p.ptr
+}
+
+// Fd returns the descriptor of the PTY master.
+//
+func (p *Pty) Fd() int {
+	return int(C.vte_pty_get_fd(p.Native()))
+}
+
+// SetSize sets the size of the PTY in rows and columns.
+//
+func (p *Pty) SetSize(rows, cols int) error {
+	var cerr *C.GError
+	C.vte_pty_set_size(p.Native(), C.int(rows), C.int(cols), &cerr)
+	if cerr != nil {
+		return takeError(cerr)
+	}
+	return nil
+}
+
+// GetSize returns the size of the PTY in rows and columns.
+//
+func (p *Pty) GetSize() (rows, cols int, e error) {
+	var crows, ccols C.int
+	var cerr *C.GError
+	C.vte_pty_get_size(p.Native(), &crows, &ccols, &cerr)
+	if cerr != nil {
+		return 0, 0, takeError(cerr)
+	}
+	return int(crows), int(ccols), nil
+}
+
+// SetUTF8 tells the kernel whether the terminal is UTF-8 or not, so it can
+// handle erase characters correctly in canonical mode.
+//
+func (p *Pty) SetUTF8(utf8 bool) error {
+	var cerr *C.GError
+	C.vte_pty_set_utf8(p.Native(), cbool(utf8), &cerr)
+	if cerr != nil {
+		return takeError(cerr)
+	}
+	return nil
+}
+
+// Close releases the PTY. The master descriptor is closed when the terminal
+// using it drops it too.
+//
+func (p *Pty) Close() {
+	if p.ptr == nil {
+		return
+	}
+	C.unrefPty(p.ptr)
+	p.ptr = nil
+	runtime.SetFinalizer(p, nil)
+}
+
+// SetupExecCmd prepares an os/exec command to run on the PTY. It's the Go side
+// of the Vte child setup.
+//
+// The PTY slave is set as the command standard input, output and error, and
+// the command is started in a new session with the PTY as controlling terminal.
+//
+// The returned tty is the PTY slave. Close it once cmd.Start returned, so the
+// PTY gets the end of the stream when the command exits.
+//
+func (p *Pty) SetupExecCmd(cmd *exec.Cmd) (tty *os.File, e error) {
+	cname := C.ptsName(C.vte_pty_get_fd(p.Native()))
+	if cname == nil {
+		return nil, errors.New("can't find the PTY slave name")
+	}
+	name := C.GoString(cname)
+	C.g_free(C.gpointer(unsafe.Pointer(cname)))
+
+	tty, e = os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if e != nil {
+		return nil, e
+	}
+
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0 // Child descriptor number: stdin.
+	return tty, nil
+}
+
+// PtyNew creates a new PTY suitable for the terminal. It's a wrapper around
+// vte_terminal_pty_new_sync().
+//
+func (v *Terminal) PtyNew(flags PtyFlags) (*Pty, error) {
+	var cerr *C.GError
+	c := C.vte_terminal_pty_new_sync(v.Native(), C.VtePtyFlags(flags), nil, &cerr)
+	if cerr != nil {
+		return nil, takeError(cerr)
+	}
+	return wrapPty(c), nil
+}
+
+// SetPty sets the PTY used by the terminal, or nil to unset it.
+//
+func (v *Terminal) SetPty(pty *Pty) {
+	var c *C.VtePty
+	if pty != nil {
+		c = pty.Native()
+	}
+	C.vte_terminal_set_pty(v.Native(), c)
+}
+
+// Pty returns the PTY used by the terminal, or nil if it has none.
+//
+func (v *Terminal) Pty() *Pty {
+	c := C.vte_terminal_get_pty(v.Native())
+	if c == nil {
+		return nil
+	}
+	return wrapPty(C.refPty(c))
+}
@@ -0,0 +1,107 @@
+package vte
+
+// escMaxPayload is the longest sequence payload kept, longer ones are ignored.
+//
+const escMaxPayload = 4096
+
+// Escape scanner states.
+const (
+	escGround = iota
+	escEscape // After ESC.
+	escCSI    // In a control sequence.
+	escOSC    // In an operating system command.
+	escOSCEsc // After ESC in an operating system command.
+)
+
+// escScanner finds the control sequences (CSI) and operating system commands
+// (OSC) of a byte stream received in chunks. It only knows the 7-bit forms,
+// like Vte in UTF-8 mode.
+//
+type escScanner struct {
+	state    int
+	payload  []byte // Parameters of the CSI, or text of the OSC, being read.
+	overflow bool   // The payload is too long, the sequence is ignored.
+}
+
+// scan calls found for every sequence completed in the chunk, with the offset
+// after its last byte. The kind is ']' for an OSC, or the final byte of a CSI.
+// The payload is only valid during the call.
+//
+func (sc *escScanner) scan(chunk []byte, found func(end int, kind byte, payload []byte)) {
+	for i, b := range chunk {
+		if kind, ok := sc.step(b); ok && !sc.overflow {
+			found(i+1, kind, sc.payload)
+		}
+	}
+}
+
+// step reads a byte and returns the kind of the sequence it completes, if any.
+//
+func (sc *escScanner) step(b byte) (byte, bool) {
+	switch sc.state {
+	case escEscape:
+		switch b {
+		case '[':
+			sc.begin(escCSI)
+		case ']':
+			sc.begin(escOSC)
+		case 0x1b:
+		default:
+			sc.state = escGround
+		}
+
+	case escCSI:
+		switch {
+		case b >= 0x40 && b <= 0x7e:
+			sc.state = escGround
+			return b, true
+		case b >= 0x20 && b <= 0x3f:
+			sc.add(b)
+		case b == 0x1b:
+			sc.state = escEscape
+		case b == 0x18 || b == 0x1a: // CAN and SUB cancel the sequence.
+			sc.state = escGround
+		}
+
+	case escOSC:
+		switch b {
+		case 0x07: // BEL terminator.
+			sc.state = escGround
+			return ']', true
+		case 0x1b:
+			sc.state = escOSCEsc
+		case 0x18, 0x1a:
+			sc.state = escGround
+		default:
+			sc.add(b)
+		}
+
+	case escOSCEsc:
+		if b == '\\' { // ST terminator.
+			sc.state = escGround
+			return ']', true
+		}
+		sc.state = escEscape // Another sequence starts.
+		return sc.step(b)
+
+	default:
+		if b == 0x1b {
+			sc.state = escEscape
+		}
+	}
+	return 0, false
+}
+
+func (sc *escScanner) begin(state int) {
+	sc.state = state
+	sc.payload = sc.payload[:0]
+	sc.overflow = false
+}
+
+func (sc *escScanner) add(b byte) {
+	if len(sc.payload) >= escMaxPayload {
+		sc.overflow = true
+		return
+	}
+	sc.payload = append(sc.payload, b)
+}
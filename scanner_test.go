@@ -0,0 +1,53 @@
+package vte
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scanned is a sequence found by the scanner, with the chunk it ended in.
+//
+type scanned struct {
+	chunk   int
+	end     int
+	kind    byte
+	payload string
+}
+
+func TestEscScanner(t *testing.T) {
+	long := strings.Repeat("x", escMaxPayload+1)
+
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []scanned
+	}{
+		{"text only", []string{"hello\r\n"}, nil},
+		{"osc bel", []string{"a\x1b]133;A\x07b"}, []scanned{{0, 9, ']', "133;A"}}},
+		{"osc st", []string{"\x1b]0;title\x1b\\"}, []scanned{{0, 11, ']', "0;title"}}},
+		{"osc split", []string{"\x1b]0;ti", "tle\x1b", "\\"}, []scanned{{2, 1, ']', "0;title"}}},
+		{"csi", []string{"\x1b[5n"}, []scanned{{0, 4, 'n', "5"}}},
+		{"csi private", []string{"\x1b[?25h"}, []scanned{{0, 6, 'h', "?25"}}},
+		{"csi split", []string{"\x1b[", "5", "n"}, []scanned{{2, 1, 'n', "5"}}},
+		{"csi cancelled", []string{"\x1b[5\x18n"}, nil},
+		{"osc cancelled", []string{"\x1b]9;x\x1aabc\x07"}, nil},
+		{"double escape", []string{"\x1b\x1b[0m"}, []scanned{{0, 5, 'm', "0"}}},
+		{"osc ended by sequence", []string{"\x1b]1;x\x1b[5n"}, []scanned{{0, 9, 'n', "5"}}},
+		{"other escape", []string{"\x1b(B\x1b[m"}, []scanned{{0, 6, 'm', ""}}},
+		{"osc too long", []string{"\x1b]" + long + "\x07\x1b]133;B\x07"}, []scanned{{0, len(long) + 11, ']', "133;B"}}},
+		{"sequences", []string{"\x1b]133;D;0\x07\x1b[6n"}, []scanned{{0, 10, ']', "133;D;0"}, {0, 14, 'n', "6"}}},
+	}
+	for _, test := range tests {
+		var sc escScanner
+		var got []scanned
+		for i, chunk := range test.chunks {
+			sc.scan([]byte(chunk), func(end int, kind byte, payload []byte) {
+				got = append(got, scanned{i, end, kind, string(payload)})
+			})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
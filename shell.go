@@ -0,0 +1,63 @@
+package vte
+
+import "path/filepath"
+
+// DefaultShell is the shell used when the user shell is unknown.
+//
+const DefaultShell = "/bin/sh"
+
+// ShellOptions defines how to start a shell with SpawnShell.
+//
+type ShellOptions struct {
+	Shell   string            // Shell overrides the user shell path.
+	Login   bool              // Login starts a login shell, with argv[0] prefixed by "-".
+	Command string            // Command is run by the shell with -c, instead of an interactive session.
+	Dir     string            // Dir specifies the working directory of the shell.
+	Env     map[string]string // Env specifies variables set over the parent environment.
+	OnExec  func(int, error)  // OnExec is called when the shell is started (or failed to).
+}
+
+// UserShell returns the user's shell, or DefaultShell if it's unknown.
+//
+func UserShell() string {
+	if shell := GetUserShell(); shell != "" {
+		return shell
+	}
+	return DefaultShell
+}
+
+// Cmd returns the command to start the shell. The shell inherits the parent
+// environment.
+//
+func (opts ShellOptions) Cmd() Cmd {
+	shell := opts.Shell
+	if shell == "" {
+		shell = UserShell()
+	}
+
+	argv0 := shell
+	if opts.Login {
+		argv0 = "-" + filepath.Base(shell)
+	}
+
+	args := []string{shell, argv0} // File to run, then argv.
+	if opts.Command != "" {
+		args = append(args, "-c", opts.Command)
+	}
+
+	return Cmd{
+		Dir:        opts.Dir,
+		Args:       args,
+		Timeout:    -1,
+		Env:        opts.Env,
+		OnExec:     opts.OnExec,
+		InheritEnv: true,
+		SpawnFlags: SpawnFileAndArgvZero,
+	}
+}
+
+// SpawnShell starts the user's shell in the terminal.
+//
+func (v *Terminal) SpawnShell(opts ShellOptions) (*Process, error) {
+	return v.ExecAsync(opts.Cmd())
+}
@@ -0,0 +1,251 @@
+package vte
+
+/*
+#include <stdlib.h>
+#include <vte/vte.h>
+
+// Go exported func redeclarations.
+extern void onSignal (gpointer data);
+extern void onSignalStatus (gint status, gpointer data);
+extern void onSignalText (gchar *text, guint size, gpointer data);
+extern void onSignalSize (guint width, guint height, gpointer data);
+extern void onSignalHover (gchar *uri, GdkRectangle *bbox, gpointer data);
+extern void onSignalDestroy (gpointer data, GClosure *closure);
+
+
+static inline void signalVoid(VteTerminal *terminal, gpointer data) {
+	onSignal(data);
+}
+
+static inline void signalStatus(VteTerminal *terminal, gint status, gpointer data) {
+	onSignalStatus(status, data);
+}
+
+static inline void signalText(VteTerminal *terminal, gchar *text, guint size, gpointer data) {
+	onSignalText(text, size, data);
+}
+
+static inline void signalSize(VteTerminal *terminal, guint width, guint height, gpointer data) {
+	onSignalSize(width, height, data);
+}
+
+static inline void signalHover(VteTerminal *terminal, gchar *uri, GdkRectangle *bbox, gpointer data) {
+	onSignalHover(uri, bbox, data);
+}
+
+static inline gulong connectSignal(VteTerminal *terminal, const gchar *name, GCallback callback, gpointer data) {
+	if (g_signal_lookup(name, G_OBJECT_TYPE(terminal)) == 0) {
+		return 0; // Signal of a newer Vte.
+	}
+	return g_signal_connect_data(terminal, name, callback, data, onSignalDestroy, 0);
+}
+*/
+import "C"
+
+import "unsafe"
+
+// SignalHandle identifies a hook connected to a terminal signal, or to an
+// event detected by the package.
+//
+type SignalHandle struct {
+	terminal *C.VteTerminal
+	id       C.gulong
+	remove   func() // Removes a hook of the package, instead of a signal one.
+}
+
+// Disconnect disconnects the hook from the signal. It does nothing if the hook
+// was already disconnected or the terminal destroyed.
+//
+func (h *SignalHandle) Disconnect() {
+	if h == nil {
+		return
+	}
+	if h.remove != nil {
+		h.remove()
+		h.remove = nil
+		return
+	}
+	if h.id == 0 {
+		return
+	}
+	if findState(h.terminal) != nil { // Not finalized.
+		C.g_signal_handler_disconnect(C.gpointer(unsafe.Pointer(h.terminal)), h.id)
+	}
+	h.id = 0
+}
+
+// connect connects the Go hook to the signal, through the C trampoline
+// matching the signal arguments. Signals unknown to the Vte version used are
+// ignored.
+//
+func (v *Terminal) connect(signal string, trampoline C.GCallback, hook interface{}) *SignalHandle {
+	cname := C.CString(signal)
+	defer C.free(unsafe.Pointer(cname))
+	data := v.newCallback("signal", hook)
+	id := C.connectSignal(v.Native(), cname, trampoline, data)
+	if id == 0 {
+		takeCallback(data)
+	}
+	return &SignalHandle{terminal: v.ptr, id: id}
+}
+
+// OnChildExited connects a hook called with the exit status when the child
+// process watched by the terminal exits.
+//
+func (v *Terminal) OnChildExited(call func(ExitStatus)) *SignalHandle {
+	return v.connect("child-exited", C.GCallback(C.signalStatus), func(status C.gint) {
+		call(ExitStatus(status))
+	})
+}
+
+// OnContentsChanged connects a hook called when the visible content of the
+// terminal changed.
+//
+func (v *Terminal) OnContentsChanged(call func()) *SignalHandle {
+	return v.connect("contents-changed", C.GCallback(C.signalVoid), call)
+}
+
+// OnCursorMoved connects a hook called when the cursor moved.
+//
+func (v *Terminal) OnCursorMoved(call func()) *SignalHandle {
+	return v.connect("cursor-moved", C.GCallback(C.signalVoid), call)
+}
+
+// OnWindowTitleChanged connects a hook called when the window title was set
+// by the child.
+//
+func (v *Terminal) OnWindowTitleChanged(call func()) *SignalHandle {
+	return v.connect("window-title-changed", C.GCallback(C.signalVoid), call)
+}
+
+// OnCurrentDirectoryURIChanged connects a hook called when the child set its
+// current directory URI.
+//
+func (v *Terminal) OnCurrentDirectoryURIChanged(call func()) *SignalHandle {
+	return v.connect("current-directory-uri-changed", C.GCallback(C.signalVoid), call)
+}
+
+// OnCurrentFileURIChanged connects a hook called when the child set its
+// current file URI.
+//
+func (v *Terminal) OnCurrentFileURIChanged(call func()) *SignalHandle {
+	return v.connect("current-file-uri-changed", C.GCallback(C.signalVoid), call)
+}
+
+// OnBell connects a hook called when the child rings the bell.
+//
+func (v *Terminal) OnBell(call func()) *SignalHandle {
+	return v.connect("bell", C.GCallback(C.signalVoid), call)
+}
+
+// OnCommit connects a hook called with the text the terminal sends to the
+// child: keyboard input, pastes and FeedChild data.
+//
+func (v *Terminal) OnCommit(call func(text string)) *SignalHandle {
+	return v.connect("commit", C.GCallback(C.signalText), call)
+}
+
+// OnEOF connects a hook called when the child output reached the end.
+//
+func (v *Terminal) OnEOF(call func()) *SignalHandle {
+	return v.connect("eof", C.GCallback(C.signalVoid), call)
+}
+
+// OnSelectionChanged connects a hook called when the selection changed.
+//
+func (v *Terminal) OnSelectionChanged(call func()) *SignalHandle {
+	return v.connect("selection-changed", C.GCallback(C.signalVoid), call)
+}
+
+// OnCharSizeChanged connects a hook called with the new size of a character
+// cell in pixels, when the font changed.
+//
+func (v *Terminal) OnCharSizeChanged(call func(width, height int)) *SignalHandle {
+	return v.connect("char-size-changed", C.GCallback(C.signalSize), call)
+}
+
+// OnHoverURIChanged connects a hook called when the pointer hovers a new
+// hyperlink, with its URI and bounding box. The URI is empty when the pointer
+// leaves the hyperlink. Needs Vte 0.50.
+//
+func (v *Terminal) OnHoverURIChanged(call func(uri string, bbox Rectangle)) *SignalHandle {
+	return v.connect("hover-uri-changed", C.GCallback(C.signalHover), call)
+}
+
+// OnIncreaseFontSize connects a hook called when the user asks for a bigger
+// font (Ctrl+Plus).
+//
+func (v *Terminal) OnIncreaseFontSize(call func()) *SignalHandle {
+	return v.connect("increase-font-size", C.GCallback(C.signalVoid), call)
+}
+
+// OnDecreaseFontSize connects a hook called when the user asks for a smaller
+// font (Ctrl+Minus).
+//
+func (v *Terminal) OnDecreaseFontSize(call func()) *SignalHandle {
+	return v.connect("decrease-font-size", C.GCallback(C.signalVoid), call)
+}
+
+//export onSignal
+//
+// called for terminal signals without arguments.
+//
+func onSignal(data C.gpointer) {
+	if call, ok := lookupCallback(data).(func()); ok {
+		call()
+	}
+}
+
+//export onSignalStatus
+//
+// called for the terminal child-exited signal.
+//
+func onSignalStatus(status C.gint, data C.gpointer) {
+	if call, ok := lookupCallback(data).(func(C.gint)); ok {
+		call(status)
+	}
+}
+
+//export onSignalText
+//
+// called for the terminal commit signal.
+//
+func onSignalText(text *C.gchar, size C.guint, data C.gpointer) {
+	if call, ok := lookupCallback(data).(func(string)); ok {
+		call(C.GoStringN((*C.char)(unsafe.Pointer(text)), C.int(size)))
+	}
+}
+
+//export onSignalSize
+//
+// called for the terminal char-size-changed signal.
+//
+func onSignalSize(width, height C.guint, data C.gpointer) {
+	if call, ok := lookupCallback(data).(func(int, int)); ok {
+		call(int(width), int(height))
+	}
+}
+
+//export onSignalHover
+//
+// called for the terminal hover-uri-changed signal.
+//
+func onSignalHover(uri *C.gchar, bbox *C.GdkRectangle, data C.gpointer) {
+	call, ok := lookupCallback(data).(func(string, Rectangle))
+	if !ok {
+		return
+	}
+	var rect Rectangle
+	if bbox != nil {
+		rect = Rectangle{int(bbox.x), int(bbox.y), int(bbox.width), int(bbox.height)}
+	}
+	call(C.GoString((*C.char)(unsafe.Pointer(uri))), rect)
+}
+
+//export onSignalDestroy
+//
+// called when a hook is disconnected or the terminal destroyed.
+//
+func onSignalDestroy(data C.gpointer, closure *C.GClosure) {
+	takeCallback(data)
+}
@@ -0,0 +1,166 @@
+package vte
+
+/*
+#include <vte/vte.h>
+
+// Go exported func redeclarations.
+extern void onChildExited (VteTerminal *terminal, gint status, gpointer data);
+extern void onTerminalFinalized (gpointer data, GObject *terminal);
+
+
+static inline void connectChildExited(VteTerminal *terminal) {
+	g_signal_connect(terminal, "child-exited", G_CALLBACK(onChildExited), NULL);
+}
+
+static inline void watchTerminalFinalize(VteTerminal *terminal) {
+	g_object_weak_ref(G_OBJECT(terminal), onTerminalFinalized, NULL);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// termState holds the Go side data of a terminal, shared by all Terminal
+// values wrapping the same VteTerminal.
+//
+type termState struct {
+	mu      sync.Mutex
+	process *Process // Last process started, until it exits.
+
+	geometry   bool                    // The geometry signals are connected.
+	cols, rows int                     // Last grid size seen.
+	resize     []*func(cols, rows int) // Hooks called when the grid size changed.
+
+	stream     *stream // Stream of the running command.
+	outputTaps []*Tap
+	inputTaps  []*Tap
+
+	events   []*eventQueue   // Queues of the open Events channels.
+	commands *commandTracker // Tracker of the shell commands, if enabled.
+
+	notification []*func(title, body string)               // Hooks called for notification sequences.
+	progress     []*func(state ProgressState, percent int) // Hooks called for progress sequences.
+}
+
+var terminalStates = make(map[*C.VteTerminal]*termState)
+var terminalStatesMU = sync.Mutex{}
+
+// state returns the Go side data of the terminal.
+// The first call connects the signals needed to keep it updated.
+//
+func (v *Terminal) state() *termState {
+	terminalStatesMU.Lock()
+	defer terminalStatesMU.Unlock()
+	st, ok := terminalStates[v.ptr]
+	if !ok {
+		st = &termState{}
+		terminalStates[v.ptr] = st
+		C.connectChildExited(v.ptr)
+		connectCommit(v.ptr)
+		C.watchTerminalFinalize(v.ptr)
+	}
+	return st
+}
+
+// findState returns the Go side data of the terminal, if any.
+//
+func findState(terminal *C.VteTerminal) *termState {
+	terminalStatesMU.Lock()
+	defer terminalStatesMU.Unlock()
+	return terminalStates[terminal]
+}
+
+// running returns whether a process started in the terminal is still running.
+//
+func (st *termState) running() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.process == nil {
+		return false
+	}
+	select {
+	case <-st.process.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// isInterposed returns whether commands are started on a PTY relayed by a
+// stream: while output taps, the command tracker or sequence hooks need it.
+//
+func (st *termState) isInterposed() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.outputTaps) > 0 || st.commands != nil || len(st.notification) > 0 || len(st.progress) > 0
+}
+
+// setProcess sets the process started in the terminal.
+//
+func (st *termState) setProcess(proc *Process) {
+	st.mu.Lock()
+	st.process = proc
+	st.mu.Unlock()
+}
+
+//export onChildExited
+//
+// called when the child process of the terminal exited.
+//
+func onChildExited(terminal *C.VteTerminal, cstatus C.gint, data C.gpointer) {
+	st := findState(terminal)
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	proc := st.process
+	st.process = nil
+	st.mu.Unlock()
+
+	if proc != nil { // Connected first, finished before the OnChildExited hooks are called.
+		proc.finish(ExitStatus(cstatus), nil)
+	}
+}
+
+//export onTerminalFinalized
+//
+// called when the terminal is destroyed.
+//
+func onTerminalFinalized(data C.gpointer, terminal *C.GObject) {
+	st := findState((*C.VteTerminal)(unsafe.Pointer(terminal)))
+	if st != nil {
+		st.mu.Lock()
+		strm := st.stream
+		st.mu.Unlock()
+		strm.close() // Before the state is dropped, the stream goroutines use it.
+	}
+
+	terminalStatesMU.Lock()
+	delete(terminalStates, (*C.VteTerminal)(unsafe.Pointer(terminal)))
+	terminalStatesMU.Unlock()
+
+	releaseTerminalCallbacks((*C.VteTerminal)(unsafe.Pointer(terminal)))
+
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	proc := st.process
+	taps := append(append([]*Tap(nil), st.outputTaps...), st.inputTaps...)
+	events := st.events
+	st.mu.Unlock()
+	if proc != nil {
+		proc.finish(0, errors.New("terminal destroyed"))
+	}
+	for _, t := range taps {
+		t.Remove()
+	}
+	for _, q := range events {
+		q.release()
+	}
+}
@@ -0,0 +1,369 @@
+package vte
+
+/*
+#include <fcntl.h>
+#include <vte/vte.h>
+
+// Go exported func redeclarations.
+extern void onStreamChildExited (GPid pid, gint status, gpointer data);
+extern void onSourceDestroy (gpointer data);
+
+
+static inline int dupCloexec(int fd) {
+	return fcntl(fd, F_DUPFD_CLOEXEC, 3);
+}
+
+static inline guint childWatchAdd(GPid pid, gpointer data) {
+	return g_child_watch_add_full(G_PRIORITY_DEFAULT, pid, onStreamChildExited, data, onSourceDestroy);
+}
+
+static inline void emitChildExited(VteTerminal *terminal, int status) {
+	g_signal_emit_by_name(terminal, "child-exited", status);
+}
+*/
+import "C"
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// streamExitDelay is how long the end of the output is awaited once the child
+// exited, as background processes can keep the PTY open.
+//
+const streamExitDelay = 500 * time.Millisecond
+
+// streamMaxPending is the size of the output waiting to be fed above which the
+// PTY is no longer read, so a flooding child waits for the terminal like
+// without relay.
+//
+const streamMaxPending = 256 * 1024
+
+// stream relays the PTY of a command to the terminal, so its byte streams can
+// be seen before Vte gets them. The output is fed to the terminal and the
+// terminal input is written to the PTY, as the terminal has no PTY itself.
+//
+type stream struct {
+	term   *Terminal
+	st     *termState
+	pty    *Pty
+	master *os.File // Own descriptor on the PTY master, used by the goroutines.
+
+	mu         sync.Mutex
+	pending    [][]byte      // Output waiting to be fed, a nil chunk marks its end.
+	pendingLen int           // Size of the pending output.
+	drained    *sync.Cond    // Signaled when the pending output is fed.
+	flushing   bool          // A flush is scheduled in the main loop.
+	input      [][]byte      // Input waiting to be written to the PTY.
+	inputReady chan struct{} // Wakes the input writer.
+	closed     bool
+
+	// Main loop only.
+	scanner escScanner
+	tracker *commandTracker // Last tracker fed, it takes the status report answers.
+	eof     bool            // The output ended.
+	exited  bool            // The child exited.
+	status  ExitStatus      // Exit status of the child.
+	timer   uint            // Source waiting for the end of the output.
+}
+
+// newStream creates the PTY of a command relayed by the terminal.
+//
+func (v *Terminal) newStream(flags PtyFlags) (*stream, error) {
+	pty, e := NewPty(flags)
+	if e != nil {
+		return nil, e
+	}
+	pty.SetSize(v.GetRowCount(), v.GetColumnCount())
+
+	fd, e := C.dupCloexec(C.int(pty.Fd()))
+	if fd < 0 {
+		pty.Close()
+		return nil, os.NewSyscallError("dup", e)
+	}
+	syscall.SetNonblock(int(fd), true) // Pollable, so closing it stops the reader.
+
+	s := &stream{
+		term:       v,
+		st:         v.state(),
+		pty:        pty,
+		master:     os.NewFile(uintptr(fd), "ptmx"),
+		inputReady: make(chan struct{}, 1),
+	}
+	s.drained = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+// native returns the PTY to spawn the command on, or nil for a stream-less
+// command.
+//
+func (s *stream) native() *C.VtePty {
+	if s == nil {
+		return nil
+	}
+	return s.pty.Native()
+}
+
+// start relays the PTY of the started child.
+//
+func (s *stream) start(pid int) {
+	if s == nil {
+		return
+	}
+	s.term.watchGeometry() // Resizes the PTY.
+	s.st.mu.Lock()
+	s.st.stream = s
+	s.st.mu.Unlock()
+
+	C.childWatchAdd(C.GPid(pid), s.term.newCallback("child-watch", s))
+	go s.readOutput()
+	go s.writeInput()
+}
+
+// readOutput reads the PTY output, sends it to the taps and queues it for the
+// terminal. Reading pauses while too much output waits to be fed.
+//
+func (s *stream) readOutput() {
+	buf := make([]byte, 32*1024)
+	for {
+		if !s.waitDrained() {
+			return
+		}
+		n, e := s.master.Read(buf)
+		if n > 0 {
+			chunk := append([]byte(nil), buf[:n]...)
+			s.st.tapOutput(chunk)
+			s.push(chunk)
+		}
+		if e != nil { // EIO once no process has the PTY slave open.
+			s.push(nil)
+			return
+		}
+	}
+}
+
+// waitDrained waits until the pending output is below the limit. Returns false
+// if the stream was closed.
+//
+func (s *stream) waitDrained() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.pendingLen >= streamMaxPending && !s.closed {
+		s.drained.Wait()
+	}
+	return !s.closed
+}
+
+// push queues the output chunk and schedules a flush.
+//
+func (s *stream) push(chunk []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.pending = append(s.pending, chunk)
+	s.pendingLen += len(chunk)
+	if !s.flushing {
+		s.flushing = true
+		s.term.idleAdd(func() bool { s.flush(); return false })
+	}
+}
+
+// flush feeds the queued output to the terminal, and emits the child exit
+// once the output ended.
+//
+func (s *stream) flush() {
+	s.mu.Lock()
+	chunks := s.pending
+	s.pending = nil
+	s.pendingLen = 0
+	s.flushing = false
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return
+	}
+
+	for _, chunk := range chunks {
+		if chunk == nil {
+			s.eof = true
+			continue
+		}
+		s.feed(chunk)
+	}
+
+	s.mu.Lock()
+	s.drained.Signal() // Fed to Vte, read more.
+	s.mu.Unlock()
+
+	if s.eof && s.exited {
+		s.exit()
+	}
+}
+
+// exit closes the stream and emits the terminal child-exited signal.
+//
+func (s *stream) exit() {
+	sourceRemove(s.timer)
+	s.timer = 0
+	s.close()
+	C.emitChildExited(s.term.Native(), C.int(s.status))
+}
+
+// feed feeds the output chunk to the terminal, and follows the sequences used
+// by the terminal trackers and hooks.
+//
+func (s *stream) feed(chunk []byte) {
+	s.st.mu.Lock()
+	commands := s.st.commands
+	hooks := len(s.st.notification) > 0 || len(s.st.progress) > 0
+	s.st.mu.Unlock()
+	if commands == nil && !hooks {
+		s.term.feedBytes(chunk)
+		return
+	}
+
+	start := 0
+	s.scanner.scan(chunk, func(end int, kind byte, payload []byte) {
+		switch kind {
+		case 'n': // Status report asked by the child, answered in order with ours.
+			if commands != nil && string(payload) == "5" {
+				s.tracker = commands
+				commands.feed(nil)
+			}
+
+		case ']':
+			if mark := parseShellMark(payload); mark != nil {
+				if commands != nil {
+					s.term.feedBytes(chunk[start:end])
+					s.term.feedBytes([]byte(statusReport))
+					s.tracker = commands
+					commands.feed(mark)
+					start = end
+				}
+				return
+			}
+			s.st.handleOSC(payload)
+		}
+	})
+	s.term.feedBytes(chunk[start:])
+}
+
+// write queues the input chunk for the PTY.
+//
+func (s *stream) write(chunk []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.input = append(s.input, chunk)
+	select {
+	case s.inputReady <- struct{}{}:
+	default:
+	}
+}
+
+// writeInput writes the queued input to the PTY until the stream is closed.
+//
+func (s *stream) writeInput() {
+	for range s.inputReady {
+		s.mu.Lock()
+		chunks := s.input
+		s.input = nil
+		s.mu.Unlock()
+
+		for _, chunk := range chunks {
+			if _, e := s.master.Write(chunk); e != nil {
+				break
+			}
+		}
+	}
+}
+
+// close stops relaying the PTY. The child gets a hangup if it still runs.
+//
+func (s *stream) close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.pending = nil
+	s.pendingLen = 0
+	s.input = nil
+	close(s.inputReady)
+	s.drained.Broadcast()
+	s.mu.Unlock()
+
+	s.master.Close()
+	s.pty.Close()
+
+	s.st.mu.Lock()
+	if s.st.stream == s {
+		s.st.stream = nil
+	}
+	s.st.mu.Unlock()
+}
+
+// feedBytes sends raw data to the terminal for processing.
+//
+func (v *Terminal) feedBytes(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	C.vte_terminal_feed(v.Native(), (*C.char)(unsafe.Pointer(&data[0])), C.gssize(len(data)))
+}
+
+// commandPty returns the PTY of the running command: the one of the stream
+// relaying it, or the terminal one. Returns nil if there is none.
+//
+func (v *Terminal) commandPty() *Pty {
+	st := v.state()
+	st.mu.Lock()
+	strm := st.stream
+	st.mu.Unlock()
+	if strm != nil {
+		return strm.pty.ref()
+	}
+	return v.Pty()
+}
+
+//export onStreamChildExited
+//
+// called when the child of a relayed PTY exited.
+//
+func onStreamChildExited(pid C.GPid, cstatus C.gint, data C.gpointer) {
+	s, ok := lookupCallback(data).(*stream)
+	if !ok {
+		return // Released with the terminal.
+	}
+	s.exited = true
+	s.status = ExitStatus(cstatus)
+	s.flush()
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if !closed {
+		s.timer = s.term.timeoutAdd(streamExitDelay, func() bool {
+			s.timer = 0
+			s.flush()
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if !closed {
+				s.exit()
+			}
+			return false
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package vte
+
+import (
+	"fmt"
+	"time"
+)
+
+// RestartPolicy defines when a supervised command is restarted.
+//
+type RestartPolicy int
+
+// Restart policies.
+const (
+	RestartNever     RestartPolicy = iota // Never restart the command
+	RestartOnFailure                      // Restart when the command fails or is killed
+	RestartAlways                         // Always restart the command
+)
+
+// Supervisor runs a command in a terminal and restarts it when it exits,
+// following its policy. A banner with the exit status and the restart countdown
+// is printed in the terminal.
+//
+// The restart delay starts at MinBackoff and is doubled at each restart up to
+// MaxBackoff. It's reset when the command ran longer than MaxBackoff.
+//
+// Methods must be called from the GTK main loop.
+//
+type Supervisor struct {
+	Policy      RestartPolicy // Policy defines when to restart the command.
+	MinBackoff  time.Duration // MinBackoff is the first restart delay.
+	MaxBackoff  time.Duration // MaxBackoff is the longest restart delay.
+	MaxRestarts int           // MaxRestarts is the number of restarts allowed, or 0 for no limit.
+
+	term      *Terminal
+	cmd       Cmd
+	proc      *Process
+	started   time.Time
+	backoff   time.Duration
+	restarts  int
+	timer     uint          // Restart source id.
+	countdown uint          // Countdown display source id.
+	hook      *SignalHandle // Child exit hook, while started.
+	running   bool          // Started and not stopped.
+}
+
+// NewSupervisor creates a supervisor for the command in the terminal.
+//
+func NewSupervisor(term *Terminal, cmd Cmd, policy RestartPolicy) *Supervisor {
+	s := &Supervisor{
+		Policy:     policy,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		term:       term,
+		cmd:        cmd,
+	}
+
+	onExec := cmd.OnExec
+	s.cmd.OnExec = func(pid int, e error) {
+		if onExec != nil {
+			onExec(pid, e)
+		}
+		if e != nil && s.running {
+			s.failed(e)
+		}
+	}
+	return s
+}
+
+// Start starts the command. It does nothing if the supervisor is already
+// started.
+//
+func (s *Supervisor) Start() error {
+	if s.running {
+		return nil
+	}
+	s.stopTimers()
+	if s.hook == nil {
+		s.hook = s.term.OnChildExited(s.exited)
+	}
+	s.running = true
+	s.restarts = 0
+	s.backoff = 0
+	e := s.run()
+	if e != nil { // Nothing was started, leave other commands alone.
+		s.running = false
+		s.hook.Disconnect()
+		s.hook = nil
+	}
+	return e
+}
+
+// Stop stops restarting the command and kills it if it's running.
+//
+func (s *Supervisor) Stop() {
+	s.running = false
+	s.stopTimers()
+	s.hook.Disconnect()
+	s.hook = nil
+	if s.proc != nil {
+		s.proc.Kill()
+		s.proc = nil
+	}
+}
+
+// Process returns the running process, or nil if the command isn't running.
+//
+func (s *Supervisor) Process() *Process {
+	return s.proc
+}
+
+// Restarts returns the number of restarts since Start.
+//
+func (s *Supervisor) Restarts() int {
+	return s.restarts
+}
+
+func (s *Supervisor) run() error {
+	proc, e := s.term.ExecAsync(s.cmd)
+	if e != nil {
+		return e
+	}
+	s.proc = proc
+	s.started = time.Now()
+	return nil
+}
+
+// exited is connected to the terminal child-exited signal.
+//
+func (s *Supervisor) exited(status ExitStatus) {
+	if !s.running || s.proc == nil {
+		return
+	}
+	select {
+	case <-s.proc.Done(): // Our process, finished before the hooks are called.
+	default:
+		return
+	}
+	s.proc = nil
+
+	failed := !status.Exited() || status.ExitCode() != 0
+	if s.Policy == RestartAlways || (s.Policy == RestartOnFailure && failed) {
+		s.restart(status.String(), failed)
+		return
+	}
+	s.running = false
+	s.banner(status.String(), failed, "")
+}
+
+// failed is called when the command could not be started.
+//
+func (s *Supervisor) failed(e error) {
+	s.proc = nil
+	if s.Policy == RestartNever {
+		s.running = false
+		s.banner(e.Error(), true, "")
+		return
+	}
+	s.restart(e.Error(), true)
+}
+
+// restart prints the countdown banner and restarts the command after the
+// backoff delay.
+//
+func (s *Supervisor) restart(status string, failed bool) {
+	if s.MaxRestarts > 0 && s.restarts >= s.MaxRestarts {
+		s.running = false
+		s.banner(status, failed, fmt.Sprintf("giving up after %d restarts", s.restarts))
+		return
+	}
+
+	switch {
+	case s.backoff == 0 || time.Since(s.started) > s.MaxBackoff:
+		s.backoff = s.MinBackoff
+
+	default:
+		s.backoff *= 2
+		if s.backoff > s.MaxBackoff {
+			s.backoff = s.MaxBackoff
+		}
+	}
+	s.restarts++
+
+	deadline := time.Now().Add(s.backoff)
+	s.banner(status, failed, countdownText(deadline))
+
+	// The countdown only updates the banner, the restart has its own timer.
+	if s.backoff > time.Second {
+		s.countdown = s.term.timeoutAdd(time.Second, func() bool {
+			s.term.Feed("\r\x1b[K") // Redraw the banner line.
+			s.bannerLine(status, failed, countdownText(deadline))
+			return true
+		})
+	}
+
+	s.timer = s.term.timeoutAdd(s.backoff, func() bool {
+		s.timer = 0
+		s.stopTimers()
+		s.term.Feed("\r\n")
+		if e := s.run(); e != nil {
+			s.failed(e)
+		}
+		return false
+	})
+}
+
+// stopTimers removes the restart and countdown sources.
+//
+func (s *Supervisor) stopTimers() {
+	sourceRemove(s.timer)
+	s.timer = 0
+	sourceRemove(s.countdown)
+	s.countdown = 0
+}
+
+// countdownText returns the restart countdown, in seconds rounded up.
+//
+func countdownText(deadline time.Time) string {
+	remaining := (time.Until(deadline) + time.Second - 1) / time.Second
+	if remaining < 1 {
+		remaining = 1
+	}
+	return fmt.Sprintf("restarting in %ds", remaining)
+}
+
+// banner prints the status banner on a new line.
+//
+func (s *Supervisor) banner(status string, failed bool, info string) {
+	s.term.Feed("\r\n")
+	s.bannerLine(status, failed, info)
+	if info == "" || !s.running {
+		s.term.Feed("\r\n")
+	}
+}
+
+// bannerLine prints the status banner content: bold green on success, bold red
+// on failure.
+//
+func (s *Supervisor) bannerLine(status string, failed bool, info string) {
+	color := "32"
+	if failed {
+		color = "31"
+	}
+	text := "[" + s.cmd.Args[0] + ": " + status
+	if info != "" {
+		text += ", " + info
+	}
+	s.term.Feed("\x1b[1;" + color + "m" + text + "]\x1b[0m")
+}
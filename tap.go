@@ -0,0 +1,234 @@
+package vte
+
+/*
+#include <vte/vte.h>
+
+// Go exported func redeclarations.
+extern void onCommit (VteTerminal *terminal, gchar *text, guint size, gpointer data);
+
+
+static inline void connectCommit(VteTerminal *terminal) {
+	g_signal_connect(terminal, "commit", G_CALLBACK(onCommit), NULL);
+}
+
+static inline void stopCommit(VteTerminal *terminal) {
+	g_signal_stop_emission_by_name(terminal, "commit");
+}
+*/
+import "C"
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// tapQueueSize is the number of chunks a tap can hold before dropping data.
+//
+const tapQueueSize = 256
+
+// Tap receives a copy of a byte stream of the terminal.
+//
+// The writer is called from its own goroutine, so a slow writer never blocks
+// the GTK main loop: chunks are queued, and dropped when the queue is full.
+// Write errors are ignored, the first one is returned by Err.
+//
+type Tap struct {
+	w       io.Writer
+	queue   chan []byte
+	done    chan struct{}
+	dropped uint64 // Atomic count of dropped chunks.
+
+	mu     sync.Mutex
+	st     *termState
+	input  bool  // Tap of the input stream.
+	closed bool  // Removed from the terminal.
+	err    error // First write error.
+}
+
+// TapOutput adds a tap receiving the bytes read from the child PTY, before Vte
+// parses them.
+//
+// To see the output, the terminal relays the PTY of the child instead of letting
+// Vte read it, so the tap only receives the output of commands started after
+// the first output tap was added (Vte 0.48+). While output taps exist, started
+// commands are relayed, with side effects:
+//   - Pty returns nil: use ForegroundProcess and SignalForeground to reach them.
+//   - OnEOF hooks are never called, as Vte doesn't read the PTY.
+//   - The output is fed to Vte from the GTK main loop.
+//
+// Commands started once all output taps were removed are no longer relayed,
+// unless the command tracker or sequence hooks need it.
+//
+func (v *Terminal) TapOutput(w io.Writer) *Tap {
+	st := v.state()
+	t := newTap(st, w, false)
+	st.mu.Lock()
+	st.outputTaps = append(st.outputTaps, t)
+	st.mu.Unlock()
+	return t
+}
+
+// TapInput adds a tap receiving the bytes sent by Vte to the child: keyboard
+// input, pastes and FeedChild data.
+//
+func (v *Terminal) TapInput(w io.Writer) *Tap {
+	st := v.state()
+	t := newTap(st, w, true)
+	st.mu.Lock()
+	st.inputTaps = append(st.inputTaps, t)
+	st.mu.Unlock()
+	return t
+}
+
+func newTap(st *termState, w io.Writer, input bool) *Tap {
+	t := &Tap{
+		w:     w,
+		queue: make(chan []byte, tapQueueSize),
+		done:  make(chan struct{}),
+		st:    st,
+		input: input,
+	}
+	go t.run()
+	return t
+}
+
+// run writes the queued chunks until the tap is removed.
+//
+func (t *Tap) run() {
+	for chunk := range t.queue {
+		if _, e := t.w.Write(chunk); e != nil {
+			t.mu.Lock()
+			if t.err == nil {
+				t.err = e
+			}
+			t.mu.Unlock()
+		}
+	}
+	close(t.done)
+}
+
+// send queues the chunk, or drops it if the queue is full.
+// The chunk must not be modified afterwards.
+//
+func (t *Tap) send(chunk []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- chunk:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// Remove detaches the tap from the terminal. Chunks already queued are still
+// written, Done is closed once they are.
+//
+func (t *Tap) Remove() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	close(t.queue)
+	t.mu.Unlock()
+
+	t.st.mu.Lock()
+	defer t.st.mu.Unlock()
+	if t.input {
+		t.st.inputTaps = removeTap(t.st.inputTaps, t)
+	} else {
+		t.st.outputTaps = removeTap(t.st.outputTaps, t)
+	}
+}
+
+// Done returns a channel closed when the tap was removed and all its queued
+// chunks were written.
+//
+func (t *Tap) Done() <-chan struct{} {
+	return t.done
+}
+
+// Dropped returns the number of chunks dropped because the writer was too slow.
+//
+func (t *Tap) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// Err returns the first error returned by the writer, if any.
+//
+func (t *Tap) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// removeTap returns a new list without the tap, so lists already read by the
+// senders aren't modified.
+//
+func removeTap(list []*Tap, t *Tap) []*Tap {
+	kept := make([]*Tap, 0, len(list))
+	for _, tap := range list {
+		if tap != t {
+			kept = append(kept, tap)
+		}
+	}
+	return kept
+}
+
+// sendTaps sends the chunk to all the taps.
+//
+func sendTaps(taps []*Tap, chunk []byte) {
+	for _, t := range taps {
+		t.send(chunk)
+	}
+}
+
+// tapOutput sends the output chunk to the output taps. Safe to use from any
+// goroutine.
+//
+func (st *termState) tapOutput(chunk []byte) {
+	st.mu.Lock()
+	taps := st.outputTaps
+	st.mu.Unlock()
+	sendTaps(taps, chunk)
+}
+
+// connectCommit connects the terminal commit signal, before any hook so
+// internal answers can be hidden.
+//
+func connectCommit(terminal *C.VteTerminal) {
+	C.connectCommit(terminal)
+}
+
+//export onCommit
+//
+// called when the terminal sends data to the child.
+//
+func onCommit(terminal *C.VteTerminal, text *C.gchar, size C.guint, data C.gpointer) {
+	st := findState(terminal)
+	if st == nil {
+		return
+	}
+	chunk := C.GoBytes(unsafe.Pointer(text), C.int(size))
+
+	st.mu.Lock()
+	taps := st.inputTaps
+	strm := st.stream
+	st.mu.Unlock()
+
+	if strm != nil && strm.tracker != nil && strm.tracker.takeReply(&Terminal{terminal}, chunk) {
+		C.stopCommit(terminal) // Answer to a status report of the tracker.
+		return
+	}
+
+	sendTaps(taps, chunk)
+	if strm != nil {
+		strm.write(chunk)
+	}
+}
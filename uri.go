@@ -0,0 +1,100 @@
+package vte
+
+// #include <vte/vte.h>
+import "C"
+
+import (
+	"errors"
+	"net/url"
+	"os"
+)
+
+// ErrNoURI is returned when the terminal has no URI set.
+//
+var ErrNoURI = errors.New("no URI set")
+
+// FileURI is a file URI, as set by the child with OSC 7 (directory) or OSC 6
+// (file): file://host/path.
+//
+type FileURI struct {
+	URI  string // URI is the raw URI.
+	Host string // Host is the machine of the file, empty for the local one.
+	Path string // Path is the unescaped file path on the host.
+}
+
+// ParseFileURI parses a file URI.
+//
+func ParseFileURI(uri string) (FileURI, error) {
+	u, e := url.Parse(uri)
+	if e != nil {
+		return FileURI{}, e
+	}
+	if u.Scheme != "file" {
+		return FileURI{}, errors.New("not a file URI: " + uri)
+	}
+	if u.Path == "" {
+		return FileURI{}, errors.New("no path in URI: " + uri)
+	}
+	return FileURI{URI: uri, Host: u.Hostname(), Path: u.Path}, nil
+}
+
+// IsLocal reports whether the file is on the local machine: the host is empty,
+// localhost or the local host name.
+//
+func (u FileURI) IsLocal() bool {
+	if u.Host == "" || u.Host == "localhost" {
+		return true
+	}
+	name, e := os.Hostname()
+	return e == nil && name == u.Host
+}
+
+// LocalPath returns the path of the file if it's on the local machine.
+//
+func (u FileURI) LocalPath() (string, bool) {
+	if !u.IsLocal() {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// GetWindowTitle returns the window title set by the child, or an empty string.
+//
+func (v *Terminal) GetWindowTitle() string {
+	return C.GoString((*C.char)(C.vte_terminal_get_window_title(v.Native())))
+}
+
+// GetCurrentDirectoryURI returns the URI of the current directory of the child
+// set with OSC 7, or an empty string.
+//
+func (v *Terminal) GetCurrentDirectoryURI() string {
+	return C.GoString((*C.char)(C.vte_terminal_get_current_directory_uri(v.Native())))
+}
+
+// GetCurrentFileURI returns the URI of the current file of the child set with
+// OSC 6, or an empty string.
+//
+func (v *Terminal) GetCurrentFileURI() string {
+	return C.GoString((*C.char)(C.vte_terminal_get_current_file_uri(v.Native())))
+}
+
+// GetCurrentDirectory returns the parsed current directory URI of the child.
+// Returns ErrNoURI if it's unset.
+//
+func (v *Terminal) GetCurrentDirectory() (FileURI, error) {
+	return parseTermURI(v.GetCurrentDirectoryURI())
+}
+
+// GetCurrentFile returns the parsed current file URI of the child.
+// Returns ErrNoURI if it's unset.
+//
+func (v *Terminal) GetCurrentFile() (FileURI, error) {
+	return parseTermURI(v.GetCurrentFileURI())
+}
+
+func parseTermURI(uri string) (FileURI, error) {
+	if uri == "" {
+		return FileURI{}, ErrNoURI
+	}
+	return ParseFileURI(uri)
+}
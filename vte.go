@@ -26,20 +26,107 @@ static inline void set_string(char** strings, int n, char* str) {
 	strings[n] = str;
 }
 
+static inline void free_strings(char** strings, int count) {
+	for (int i = 0; i < count; i++) {
+		free(strings[i]);
+	}
+	free(strings);
+}
+
 
-static gpointer      uintToGpointer (uint i)      { return GUINT_TO_POINTER(i); }
-static uint          gpointerToUint (gpointer i)  { return GPOINTER_TO_UINT(i); }
 static VteTerminal * toVteTerminal (void *p)      { return (VTE_TERMINAL(p)); }
 
+#if VTE_CHECK_VERSION(0, 58, 0)
+#define VTE_GO_PTY_NO_SESSION VTE_PTY_NO_SESSION
+#define VTE_GO_PTY_NO_CTTY    VTE_PTY_NO_CTTY
+#else
+#define VTE_GO_PTY_NO_SESSION (1 << 5)
+#define VTE_GO_PTY_NO_CTTY    (1 << 6)
+#endif
+
+// onPtySpawnReady finishes a spawn on a PTY of our own, and reports it like a
+// terminal spawn.
+static void onPtySpawnReady (GObject *source, GAsyncResult *result, gpointer user_data) {
+#if VTE_CHECK_VERSION(0, 48, 0)
+	GPid pid = -1;
+	GError *error = NULL;
+	vte_pty_spawn_finish(VTE_PTY(source), result, &pid, &error);
+	onAsyncOnExec(NULL, pid, error, user_data);
+	if (error) {
+		g_error_free(error);
+	}
+#endif
+}
+
+// spawnAsync starts the command, using the fds variant when descriptors are
+// given. The command is started on pty when set, instead of a terminal PTY.
+// Returns FALSE if the variant needed is unavailable (fds: Vte < 0.62, pty: Vte < 0.48).
+static gboolean spawnAsync (VteTerminal *terminal, VtePty *pty, VtePtyFlags pty_flags,
+                            const char *working_directory, char **argv, char **envv,
+                            int *fds, int n_fds, int *map_fds, int n_map_fds,
+                            GSpawnFlags spawn_flags, GSpawnChildSetupFunc child_setup,
+                            gpointer child_setup_data, GDestroyNotify child_setup_data_destroy,
+                            int timeout, GCancellable *cancellable,
+                            VteTerminalSpawnAsyncCallback callback, gpointer user_data) {
+	if (pty != NULL) {
+#if VTE_CHECK_VERSION(0, 62, 0)
+		vte_pty_spawn_with_fds_async(pty, working_directory,
+			(char const* const*)argv, (char const* const*)envv,
+			fds, n_fds, map_fds, n_map_fds,
+			spawn_flags | G_SPAWN_DO_NOT_REAP_CHILD, child_setup, child_setup_data, child_setup_data_destroy,
+			timeout, cancellable, onPtySpawnReady, user_data);
+		return TRUE;
+#elif VTE_CHECK_VERSION(0, 48, 0)
+		if (n_fds > 0) {
+			return FALSE;
+		}
+		vte_pty_spawn_async(pty, working_directory, argv, envv,
+			spawn_flags | G_SPAWN_DO_NOT_REAP_CHILD, child_setup, child_setup_data, child_setup_data_destroy,
+			timeout, cancellable, onPtySpawnReady, user_data);
+		return TRUE;
+#else
+		return FALSE;
+#endif
+	}
+
+	if (n_fds == 0) {
+		vte_terminal_spawn_async(terminal, pty_flags, working_directory, argv, envv,
+			spawn_flags, child_setup, child_setup_data, child_setup_data_destroy,
+			timeout, cancellable, callback, user_data);
+		return TRUE;
+	}
+#if VTE_CHECK_VERSION(0, 62, 0)
+	vte_terminal_spawn_with_fds_async(terminal, pty_flags, working_directory,
+		(char const* const*)argv, (char const* const*)envv,
+		fds, n_fds, map_fds, n_map_fds,
+		spawn_flags, child_setup, child_setup_data, child_setup_data_destroy,
+		timeout, cancellable, callback, user_data);
+	return TRUE;
+#else
+	return FALSE;
+#endif
+}
+
+static gboolean spawnWithFdsSupported () { return VTE_CHECK_VERSION(0, 62, 0); }
+static gboolean ptySpawnSupported ()     { return VTE_CHECK_VERSION(0, 48, 0); }
+
+static GCancellable * refCancellable (GCancellable *c) { return g_object_ref(c); }
+static void          unrefCancellable (GCancellable *c) { g_object_unref(c); }
+
 */
 // #cgo pkg-config: vte-2.91
 import "C"
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
-	"sync"
+	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -53,6 +140,55 @@ const (
 	FormatHTML Format = C.VTE_FORMAT_HTML // Export as HTML formatted text
 )
 
+// PtyFlags defines flags used when creating the PTY of a command.
+//
+type PtyFlags int32
+
+// PTY creation flags. Lastlog, utmp, wtmp, helper and fallback flags are
+// ignored by Vte since 0.38, as it no longer logs sessions itself.
+const (
+	PtyDefault    PtyFlags = C.VTE_PTY_DEFAULT       // Default flags
+	PtyNoLastlog  PtyFlags = C.VTE_PTY_NO_LASTLOG    // Don't record the session in lastlog
+	PtyNoUtmp     PtyFlags = C.VTE_PTY_NO_UTMP       // Don't record the session in utmp
+	PtyNoWtmp     PtyFlags = C.VTE_PTY_NO_WTMP       // Don't record the session in wtmp
+	PtyNoHelper   PtyFlags = C.VTE_PTY_NO_HELPER     // Don't use the GNOME PTY helper
+	PtyNoFallback PtyFlags = C.VTE_PTY_NO_FALLBACK   // Don't fall back to a BSD PTY
+	PtyNoSession  PtyFlags = C.VTE_GO_PTY_NO_SESSION // Don't start a new session for the child (0.58+)
+	PtyNoCtty     PtyFlags = C.VTE_GO_PTY_NO_CTTY    // Don't set the PTY as controlling terminal of the child (0.58+)
+)
+
+// SpawnFlags defines flags used when spawning a command.
+//
+type SpawnFlags int32
+
+// Command spawn flags. The command is searched in PATH unless
+// SpawnNoSearchPath is set.
+const (
+	SpawnDoNotReapChild       SpawnFlags = C.G_SPAWN_DO_NOT_REAP_CHILD      // Don't reap the child automatically (Vte always sets it)
+	SpawnLeaveDescriptorsOpen SpawnFlags = C.G_SPAWN_LEAVE_DESCRIPTORS_OPEN // Keep the parent file descriptors open in the child (before Vte 0.62)
+	SpawnFileAndArgvZero      SpawnFlags = C.G_SPAWN_FILE_AND_ARGV_ZERO     // Args[0] is the file to execute, Args[1:] the full argv
+	SpawnNoSearchPath         SpawnFlags = 1 << 30                          // Don't look for the command in PATH
+)
+
+// native returns the GSpawnFlags matching the Go flags.
+//
+func (f SpawnFlags) native() C.GSpawnFlags {
+	flags := C.GSpawnFlags(f &^ SpawnNoSearchPath)
+	if f&SpawnNoSearchPath == 0 {
+		flags |= C.G_SPAWN_SEARCH_PATH
+	}
+	return flags
+}
+
+// ErrUnsupported is returned when a feature needs a more recent Vte version.
+//
+var ErrUnsupported = errors.New("unsupported by this Vte version")
+
+// ErrChildRunning is returned when starting a command in a terminal that
+// still runs one.
+//
+var ErrChildRunning = errors.New("terminal already running a child")
+
 // Colors palette names.
 //
 const (
@@ -156,10 +292,18 @@ func GetUserShell() string {
 type Cmd struct {
 	Dir     string            // Dir specifies the working directory of the command.
 	Args    []string          // Args holds command line arguments, including the command as Args[0].
-	Timeout int               // Timeout specifies the time allowed in ms, or -1 to wait indefinitely.
-	Env     map[string]string // Env specifies the environment of the process.
+	Timeout time.Duration     // Timeout specifies the time allowed to spawn, or a negative value to wait indefinitely.
+	Env     map[string]string // Env specifies environment variables of the process, applied over Environ.
 	OnExec  func(int, error)  // OnExec is called when the process is started (or failed to).
-	// Cancellable *C.GCancellable // TODO.
+
+	Environ    []string // Environ holds an ordered "key=value" environment list, like os/exec.Cmd.Env. If nil, the parent environment is used.
+	InheritEnv bool     // InheritEnv starts the environment with the one of the parent process, before Environ.
+	Unsetenv   []string // Unsetenv lists variable names removed from the environment.
+
+	PtyFlags    PtyFlags     // PtyFlags sets options of the PTY created for the command.
+	SpawnFlags  SpawnFlags   // SpawnFlags sets options of the command spawn.
+	SysProcAttr *SysProcAttr // SysProcAttr holds optional settings applied to the child before exec.
+	ExtraFiles  []*os.File   // ExtraFiles are inherited by the child as descriptor 3+i (Vte 0.62+).
 }
 
 // NewCmd creates a new command to run async in the terminal.
@@ -169,8 +313,71 @@ func (v *Terminal) NewCmd(args ...string) Cmd {
 }
 
 // ExecAsync starts the given command in the terminal.
+// The returned process can be used to follow the command until it exits.
+//
+// Problems detected before the spawn are returned as error, like an empty
+// command, a missing directory or executable, or a child already running in
+// the terminal. Later spawn failures are sent to OnExec and the process.
 //
-func (v *Terminal) ExecAsync(cmd Cmd) {
+func (v *Terminal) ExecAsync(cmd Cmd) (*Process, error) {
+	return v.ExecAsyncContext(context.Background(), cmd)
+}
+
+// ExecAsyncContext starts the given command in the terminal, like ExecAsync.
+//
+// The spawn is cancelled if the context is done before the command is started.
+// A context already done is returned as error, without calling OnExec. Once the
+// spawn began, OnExec and the process receive the context error instead
+// (context.Canceled or context.DeadlineExceeded).
+//
+// Commands with ExtraFiles fail with ErrUnsupported before Vte 0.62, like
+// commands of a terminal with output taps before Vte 0.48. Since Vte 0.62,
+// which forbids it, SpawnLeaveDescriptorsOpen fails with ErrUnsupported.
+//
+func (v *Terminal) ExecAsyncContext(ctx context.Context, cmd Cmd) (*Process, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	if len(cmd.ExtraFiles) > 0 && C.spawnWithFdsSupported() == 0 {
+		return nil, ErrUnsupported
+	}
+	if cmd.SpawnFlags&SpawnLeaveDescriptorsOpen != 0 && C.spawnWithFdsSupported() != 0 {
+		return nil, ErrUnsupported // Vte would warn and drop it.
+	}
+	if e := cmd.check(); e != nil {
+		return nil, e
+	}
+	st := v.state()
+	if st.running() {
+		return nil, ErrChildRunning
+	}
+
+	var strm *stream
+	if st.isInterposed() {
+		if C.ptySpawnSupported() == 0 {
+			return nil, ErrUnsupported
+		}
+		var e error
+		strm, e = v.newStream(cmd.PtyFlags)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	fds, mapFds, e := dupFiles(cmd.ExtraFiles)
+	if e != nil {
+		strm.close()
+		return nil, e
+	}
+
+	setupFunc, setupData, setupDestroy, setupErr, e := cmd.SysProcAttr.childSetup(len(cmd.ExtraFiles))
+	if e != nil {
+		for _, fd := range fds {
+			syscall.Close(int(fd))
+		}
+		strm.close()
+		return nil, e
+	}
 
 	var ccwd *C.char
 	if cmd.Dir != "" {
@@ -178,145 +385,304 @@ func (v *Terminal) ExecAsync(cmd Cmd) {
 		defer C.free(unsafe.Pointer(ccwd))
 	}
 
-	cargs := C.make_strings(C.int(len(cmd.Args)) + 1)
-	for i, j := range cmd.Args {
-		ptr := C.CString(j)
-		defer C.free(unsafe.Pointer(ptr))
-		C.set_string(cargs, C.int(i), ptr)
-	}
-	C.set_string(cargs, C.int(len(cmd.Args)), nil) // null terminated list.
+	cargs, freeArgs := cStrings(cmd.Args)
+	defer freeArgs()
+
+	cenv, freeEnv := cStrings(cmd.environ())
+	defer freeEnv()
 
-	cenv := C.make_strings(C.int(len(cmd.Env)) + 1)
-	i := 0
-	for k, v := range cmd.Env {
-		ptr := C.CString(fmt.Sprintf("%s=%s", k, v))
-		defer C.free(unsafe.Pointer(ptr))
-		C.set_string(cenv, C.int(i), ptr)
-		i++
+	proc := newProcess()
+	call := &asyncCall{cmd: cmd, ctx: ctx, proc: proc, stream: strm, setupErr: setupErr}
+	if ctx.Done() != nil {
+		call.cancellable = C.g_cancellable_new()
+		call.stop = make(chan struct{})
+		go call.watch(C.refCancellable(call.cancellable))
 	}
-	C.set_string(cenv, C.int(len(cmd.Env)), nil) // null terminated list.
 
-	var ccallID C.gpointer
-	if cmd.OnExec != nil {
-		callID := assignCallID(cmd)
-		if callID == 0 {
-			cmd.OnExec(0, errors.New("spawn sync is unable to store the callback for: "+strings.Join(cmd.Args, " ")))
-			return
-		}
-		ccallID = C.uintToGpointer(C.uint(callID))
-	}
-
-	C.vte_terminal_spawn_async(v.Native(),
-		C.VTE_PTY_DEFAULT, // VtePtyFlags
-		ccwd,              // const char *working_directory
-		cargs,             // char **argv
-		cenv,              // char **envv
-		C.G_SPAWN_SEARCH_PATH, // GSpawnFlags
-		nil,                // GSpawnChildSetupFunc
-		nil,                // gpointer child_setup_data
-		nil,                // GDestroyNotify for child_setup_data_destroy
-		C.int(cmd.Timeout), // int
-		nil,                // GCancellable
+	st.setProcess(proc)
+
+	spawnFlags := (cmd.SpawnFlags | spawnNoParentEnv).native() // cenv is the full environment.
+
+	data := v.newCallback("spawn", call)
+	ok := C.spawnAsync(v.Native(),
+		strm.native(),               // VtePty *pty, or NULL for a terminal PTY
+		C.VtePtyFlags(cmd.PtyFlags), // VtePtyFlags
+		ccwd,                        // const char *working_directory
+		cargs,                       // char **argv
+		cenv,                        // char **envv
+		cintsPtr(fds),               // int *fds, owned by Vte
+		C.int(len(fds)),             // int n_fds
+		cintsPtr(mapFds),            // int *map_fds
+		C.int(len(mapFds)),          // int n_map_fds
+		spawnFlags,                  // GSpawnFlags
+		setupFunc,                   // GSpawnChildSetupFunc
+		setupData,                   // gpointer child_setup_data
+		setupDestroy,                // GDestroyNotify for child_setup_data_destroy
+		cmd.timeoutMS(),             // int
+		call.cancellable,            // GCancellable
 		C.VteTerminalSpawnAsyncCallback(C.onAsyncOnExec), // VteTerminalSpawnAsyncCallback
-		ccallID, // gpointer user_data
+		data, // gpointer user_data
 	)
+	if ok == 0 { // The Vte variant needed is missing, nothing was taken.
+		takeCallback(data)
+		call.stopWatch()
+		freeChildSetupData(setupData)
+		for _, fd := range fds {
+			syscall.Close(int(fd))
+		}
+		strm.close()
+		proc.finish(0, ErrUnsupported)
+		return nil, ErrUnsupported
+	}
+	return proc, nil
+}
+
+// timeoutMS returns the spawn timeout in ms as expected by Vte. Longer delays
+// than an int holds (about 24.8 days) are clamped.
+//
+func (cmd Cmd) timeoutMS() C.int {
+	if cmd.Timeout < 0 {
+		return -1
+	}
+	ms := cmd.Timeout / time.Millisecond
+	if ms > C.G_MAXINT {
+		return C.G_MAXINT
+	}
+	return C.int(ms)
 }
 
-var asyncCallIDs = make(map[uint]Cmd)
-var asyncCallMU = sync.Mutex{}
+// defaultSpawnPath is the search path used by GLib when PATH is unset.
+//
+const defaultSpawnPath = "/bin:/usr/bin:."
+
+// check verifies the command can be started, to report errors before the spawn.
+//
+func (cmd Cmd) check() error {
+	if len(cmd.Args) == 0 || (cmd.SpawnFlags&SpawnFileAndArgvZero != 0 && len(cmd.Args) < 2) {
+		return errors.New("no command to run")
+	}
 
-func assignCallID(cmd Cmd) uint {
-	callID := uint(1)
-	asyncCallMU.Lock()
-	defer asyncCallMU.Unlock()
-	for callID != 0 {
-		_, isset := asyncCallIDs[callID]
-		if !isset {
-			asyncCallIDs[callID] = cmd
-			return callID
+	if cmd.Dir != "" {
+		fi, e := os.Stat(cmd.Dir)
+		if e != nil {
+			return e
+		}
+		if !fi.IsDir() {
+			return &os.PathError{Op: "chdir", Path: cmd.Dir, Err: syscall.ENOTDIR}
 		}
-		callID++
 	}
-	return 0
+
+	file := cmd.Args[0]
+	if strings.Contains(file, "/") || cmd.SpawnFlags&SpawnNoSearchPath != 0 {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(cmd.Dir, file) // The spawn runs from the command directory.
+		}
+		return checkExecutable(file)
+	}
+
+	// The spawn searches the PATH of the parent, like GLib does.
+	path, ok := os.LookupEnv("PATH")
+	switch {
+	case !ok:
+		path = defaultSpawnPath
+	case path == "":
+		path = "." // SplitList returns no entry.
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if checkExecutable(filepath.Join(dir, file)) == nil {
+			return nil
+		}
+	}
+	return &exec.Error{Name: file, Err: exec.ErrNotFound}
 }
 
-//export onAsyncOnExec
+// checkExecutable returns an error if the file isn't an executable.
 //
-// called when ExecAsync process is started or failed.
+func checkExecutable(file string) error {
+	fi, e := os.Stat(file)
+	if e != nil {
+		return e
+	}
+	if fi.IsDir() || fi.Mode()&0111 == 0 {
+		return &os.PathError{Op: "exec", Path: file, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+// asyncCall holds the data of an ExecAsync call waiting for its callback.
+//
+type asyncCall struct {
+	cmd         Cmd
+	ctx         context.Context
+	proc        *Process
+	cancellable *C.GCancellable // Only set when the context can be cancelled.
+	stop        chan struct{}   // Closed to stop the context watcher.
+	stream      *stream         // Stream relaying the command PTY, if interposed.
+	setupErr    int             // Pipe reporting a failed child setup, or -1.
+}
+
+// watch cancels the spawn when the context is done. The watcher owns its own
+// reference on the cancellable.
 //
-func onAsyncOnExec(terminal *C.VteTerminal, cpid C.GPid, cerr *C.GError, ccallID C.gpointer) {
-	callID := uint(C.gpointerToUint(ccallID))
-	if callID == 0 {
+func (call *asyncCall) watch(cancellable *C.GCancellable) {
+	select {
+	case <-call.ctx.Done():
+		C.g_cancellable_cancel(cancellable)
+	case <-call.stop:
+	}
+	C.unrefCancellable(cancellable)
+}
+
+// release drops the call when the terminal is finalized before the spawn
+// callback: the relay stream is closed with the rest.
+//
+func (call *asyncCall) release() {
+	call.stopWatch()
+	call.stream.close()
+}
+
+// stopWatch stops the context watcher and drops the cancellable and the setup
+// error pipe.
+//
+func (call *asyncCall) stopWatch() {
+	if call.setupErr >= 0 {
+		syscall.Close(call.setupErr)
+		call.setupErr = -1
+	}
+	if call.cancellable == nil {
 		return
 	}
+	close(call.stop)
+	C.unrefCancellable(call.cancellable)
+	call.cancellable = nil
+}
 
-	asyncCallMU.Lock()
-	cmd, ok := asyncCallIDs[callID]
-	if !ok {
-		fmt.Printf("onAsyncOnExec can't find callback ID:%d\n", callID)
-		asyncCallMU.Unlock()
+//export onAsyncOnExec
+//
+// called when ExecAsync process is started or failed.
+//
+func onAsyncOnExec(terminal *C.VteTerminal, cpid C.GPid, cerr *C.GError, data C.gpointer) {
+	call, ok := takeCallback(data).(*asyncCall)
+	if !ok { // Released with the terminal.
+		if terminal == nil && cerr == nil && cpid > 0 {
+			go syscall.Wait4(int(cpid), nil, 0, nil) // Relayed, nothing watches it. Its PTY is closed.
+		}
 		return
 	}
-	delete(asyncCallIDs, callID)
-	asyncCallMU.Unlock()
+
+	setupErr := setupError(call.setupErr)
+	call.setupErr = -1
+	call.stopWatch()
 
 	var e error
-	if cerr != nil {
+	switch {
+	case cerr != nil && call.ctx.Err() != nil:
+		e = call.ctx.Err() // The spawn was cancelled by the context.
+
+	case cerr != nil:
 		e = errors.New(C.GoString((*C.char)(cerr.message)))
 	}
-	cmd.OnExec(int(cpid), e)
+	if e == nil && setupErr != nil {
+		e = setupErr // The child exited before exec, it's reaped by the terminal.
+	}
+
+	if e != nil {
+		call.proc.finish(0, e)
+		if cerr == nil && call.stream != nil {
+			go syscall.Wait4(int(cpid), nil, 0, nil) // No child watch reaps it.
+		}
+		call.stream.close()
+	} else {
+		call.proc.start(int(cpid))
+		call.stream.start(int(cpid))
+	}
+
+	if call.cmd.OnExec != nil {
+		call.cmd.OnExec(int(cpid), e)
+	}
 }
 
-// ExecSync starts the given command in the terminal. Deprecated since 0.48.
-// It's a wrapper around vte_terminal_spawn_sync.
+// ExecSync starts the given command in the terminal and returns its pid once
+// started. It's kept for compatibility, as a wrapper around ExecAsync, since
+// vte_terminal_spawn_sync is deprecated since 0.48.
+// It must be called from the GTK main loop (or before it runs).
+//
+// The env map is applied over the parent environment, use ExecAsync with a
+// Cmd to remove variables or start from an empty environment.
 //
 func (v *Terminal) ExecSync(cwd string, args []string, env map[string]string) (int, error) {
+	started := make(chan struct{})
+	cmd := Cmd{
+		Dir:     cwd,
+		Args:    args,
+		Timeout: -1,
+		Env:     env,
+		OnExec:  func(int, error) { close(started) },
+	}
 
-	var ccwd *C.char
-	if cwd != "" {
-		ccwd = C.CString(cwd)
-		defer C.free(unsafe.Pointer(ccwd))
+	proc, e := v.ExecAsync(cmd)
+	if e != nil {
+		return 0, e
 	}
 
-	cargs := C.make_strings(C.int(len(args)) + 1)
-	for i, j := range args {
-		ptr := C.CString(j)
-		defer C.free(unsafe.Pointer(ptr))
-		C.set_string(cargs, C.int(i), ptr)
-	}
-	C.set_string(cargs, C.int(len(args)), nil) // null terminated list.
-
-	cenv := C.make_strings(C.int(len(env)) + 1)
-	i := 0
-	for k, v := range env {
-		ptr := C.CString(fmt.Sprintf("%s=%s", k, v))
-		defer C.free(unsafe.Pointer(ptr))
-		C.set_string(cenv, C.int(i), ptr)
-		i++
-	}
-	C.set_string(cenv, C.int(len(env)), nil) // null terminated list.
-
-	var cerr *C.GError
-	var cpid C.GPid
-
-	C.vte_terminal_spawn_sync(v.Native(),
-		C.VTE_PTY_DEFAULT, // VtePtyFlags
-		ccwd,              // const char *working_directory
-		cargs,             // char **argv
-		cenv,              // char **envv
-		C.G_SPAWN_SEARCH_PATH, // GSpawnFlags
-		nil,   // GSpawnChildSetupFunc
-		nil,   // gpointer child_setup_data
-		&cpid, // GPid *child_pid
-		nil,   // GCancellable *cancellable
-		&cerr, // GError **error
-	)
-	if cerr != nil {
-		defer C.g_error_free(cerr)
-		return 0, errors.New(C.GoString((*C.char)(cerr.message)))
+	// The process is also done when the terminal is destroyed before OnExec.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-proc.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	iterateUntil(ctx, started)
+
+	select {
+	case <-proc.Done(): // Failed to start or already exited.
+		if _, e := proc.Wait(); e != nil {
+			return 0, e
+		}
+	default:
 	}
+	return proc.Pid(), nil
+}
 
-	return int(cpid), nil
+// ExecWait starts the given command in the terminal and waits for it to exit,
+// by iterating the GLib main context. It replaces ExecSync, returning the exit
+// status instead of the pid.
+// It must be called from the GTK main loop (or before it runs).
+//
+// If the context is done before the command exits, the command is killed and
+// the context error is returned.
+//
+func (v *Terminal) ExecWait(ctx context.Context, cmd Cmd) (ExitStatus, error) {
+	proc, e := v.ExecAsyncContext(ctx, cmd)
+	if e != nil {
+		return 0, e
+	}
+	e = iterateUntil(ctx, proc.Done())
+	if e != nil {
+		proc.Kill()
+		return 0, e
+	}
+	return proc.Wait()
+}
+
+// ExecWaitText is like ExecWait, and also returns the text displayed in the
+// terminal while the command was running.
+//
+func (v *Terminal) ExecWaitText(ctx context.Context, cmd Cmd) (string, ExitStatus, error) {
+	_, startRow := v.GetCursorPosition()
+	status, e := v.ExecWait(ctx, cmd)
+	if e != nil {
+		return "", status, e
+	}
+	_, endRow := v.GetCursorPosition()
+	endCol := int32(v.GetColumnCount()) - 1
+	return v.GetTextRange(startRow, 0, endRow, endCol), status, nil
 }
 
 // SetBgColorFromString sets the background color for text which does not have a
@@ -406,6 +772,20 @@ func (v *Terminal) SetScrollbackLines(val int32) {
 	C.vte_terminal_set_scrollback_lines(v.Native(), C.glong(val))
 }
 
+// SetAudibleBell sets whether the terminal beeps when the child outputs the
+// "bl" sequence. The OnBell hooks are called either way.
+//
+func (v *Terminal) SetAudibleBell(audible bool) {
+	C.vte_terminal_set_audible_bell(v.Native(), cbool(audible))
+}
+
+// GetAudibleBell returns whether the terminal beeps when the child outputs
+// the "bl" sequence.
+//
+func (v *Terminal) GetAudibleBell() bool {
+	return C.vte_terminal_get_audible_bell(v.Native()) != 0
+}
+
 // GetCursorPosition reads the location of the insertion cursor and returns it.
 // The row coordinate is absolute.
 //
@@ -528,6 +908,60 @@ func parseColor(s string, color *C.GdkRGBA) {
 	C.gdk_rgba_parse(color, (*C.gchar)(cstr))
 }
 
+// takeError converts the GError to a Go error and frees it.
+//
+func takeError(cerr *C.GError) error {
+	defer C.g_error_free(cerr)
+	return errors.New(C.GoString((*C.char)(cerr.message)))
+}
+
+// dupFiles duplicates the descriptors of files to give to Vte, which takes
+// ownership of them. It also returns the descriptor numbers they are mapped to
+// in the child: 3+i for files[i].
+//
+func dupFiles(files []*os.File) (fds, mapFds []C.int, e error) {
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		fd, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_DUPFD_CLOEXEC, 3)
+		runtime.KeepAlive(f)
+		if errno != 0 {
+			for _, fd := range fds {
+				syscall.Close(int(fd))
+			}
+			return nil, nil, os.NewSyscallError("fcntl", errno)
+		}
+		fds = append(fds, C.int(fd))
+		mapFds = append(mapFds, C.int(3+i))
+	}
+	return fds, mapFds, nil
+}
+
+// cintsPtr returns a pointer to the first int of the list, or nil if empty.
+//
+func cintsPtr(list []C.int) *C.int {
+	if len(list) == 0 {
+		return nil
+	}
+	return &list[0]
+}
+
+// cStrings converts the list to a NULL terminated C strings array.
+// The returned func must be called to free the memory.
+//
+func cStrings(list []string) (**C.char, func()) {
+	cstrs := C.make_strings(C.int(len(list)) + 1)
+	for i, str := range list {
+		C.set_string(cstrs, C.int(i), C.CString(str))
+	}
+	C.set_string(cstrs, C.int(len(list)), nil) // null terminated list.
+
+	return cstrs, func() {
+		C.free_strings(cstrs, C.int(len(list)))
+	}
+}
+
 func cbool(b bool) C.gboolean {
 	if b {
 		return 1
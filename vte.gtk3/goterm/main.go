@@ -3,6 +3,8 @@ package main
 
 import (
 	"github.com/gotk3/gotk3/gtk"
+
+	vtecommon "github.com/sqp/vte"
 	"github.com/sqp/vte/vte.gtk3"
 
 	"os"
@@ -29,10 +31,18 @@ func main() {
 
 	// Settings.
 	term.SetFontFromString(TermFont)
+	term.SetAllowHyperlink(true) // Display OSC 8 links.
+	term.SetVisualBell(true)
 	win.SetSizeRequest(WinWidth, WinHeight)
 
 	// Signals.
-	term.Connect("child-exited", gtk.MainQuit)
+	term.OnChildExited(func(status vtecommon.ExitStatus) {
+		println("command ended:", status.String())
+		gtk.MainQuit()
+	})
+	term.OnWindowTitleChanged(func() {
+		win.SetTitle(term.GetWindowTitle())
+	})
 	win.Connect("destroy", gtk.MainQuit)
 
 	// Start a command. This is optional, you can fill the terminal yourself.
@@ -45,7 +55,11 @@ func main() {
 				gtk.MainQuit()
 			}
 		}
-		term.ExecAsync(cmd)
+		_, e := term.ExecAsync(cmd)
+		if e != nil {
+			println(e.Error())
+			return
+		}
 	}
 
 	gtk.Main()
@@ -0,0 +1,98 @@
+package vte
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include <vte/vte.h>
+
+// Go exported func redeclarations.
+extern void onDesktopNotifyDestroy (gpointer data);
+
+
+#define DESKTOP_NOTIFY_KEY "vte-go-desktop-notify"
+
+static inline gpointer handleToGpointer (uintptr_t h) { return (gpointer)h; }
+static inline uintptr_t gpointerToHandle (gpointer p)  { return (uintptr_t)p; }
+
+static inline gboolean hasDesktopNotify (VteTerminal *terminal) {
+	return g_object_get_data(G_OBJECT(terminal), DESKTOP_NOTIFY_KEY) != NULL;
+}
+
+// setDesktopNotify keeps the notification hook with the terminal. The
+// previous one, or the last one when the terminal is finalized, is given to
+// onDesktopNotifyDestroy.
+static inline void setDesktopNotify (VteTerminal *terminal, gpointer hook) {
+	g_object_set_data_full(G_OBJECT(terminal), DESKTOP_NOTIFY_KEY, hook, hook ? onDesktopNotifyDestroy : NULL);
+}
+
+// sendDesktopNotification sends the notification with the default
+// application, if enabled and the terminal window is unfocused.
+static inline void sendDesktopNotification (VteTerminal *terminal, const char *title, const char *body) {
+	if (!hasDesktopNotify(terminal)) {
+		return;
+	}
+	GtkWidget *top = gtk_widget_get_toplevel(GTK_WIDGET(terminal));
+	if (GTK_IS_WINDOW(top) && gtk_window_is_active(GTK_WINDOW(top))) {
+		return;
+	}
+	GApplication *app = g_application_get_default();
+	if (app == NULL) {
+		return;
+	}
+
+	GNotification *notification = g_notification_new(title);
+	if (*body) {
+		g_notification_set_body(notification, body);
+	}
+	g_application_send_notification(app, NULL, notification);
+	g_object_unref(notification);
+}
+*/
+import "C"
+
+import (
+	"github.com/sqp/vte"
+
+	"runtime/cgo"
+	"unsafe"
+)
+
+// SetDesktopNotifications sets whether the notifications asked by the child
+// (see OnNotification) are sent to the desktop with GNotification, when the
+// terminal window is unfocused. It needs a registered default GApplication,
+// like a gtk.Application.
+//
+// While enabled, commands are relayed like with OnNotification.
+//
+func (v *Terminal) SetDesktopNotifications(enabled bool) {
+	native := v.termNative()
+	if (C.hasDesktopNotify(native) != 0) == enabled {
+		return
+	}
+	if !enabled {
+		C.setDesktopNotify(native, nil) // The hook is disconnected by onDesktopNotifyDestroy.
+		return
+	}
+
+	hook := v.Terminal.OnNotification(func(title, body string) {
+		if title == "" { // OSC 9 has no title.
+			title, body = body, ""
+		}
+		ctitle := C.CString(title)
+		defer C.free(unsafe.Pointer(ctitle))
+		cbody := C.CString(body)
+		defer C.free(unsafe.Pointer(cbody))
+		C.sendDesktopNotification(native, ctitle, cbody)
+	})
+	C.setDesktopNotify(native, C.handleToGpointer(C.uintptr_t(cgo.NewHandle(hook))))
+}
+
+//export onDesktopNotifyDestroy
+//
+// called when desktop notifications are disabled or the terminal is finalized.
+//
+func onDesktopNotifyDestroy(data C.gpointer) {
+	h := cgo.Handle(C.gpointerToHandle(data))
+	h.Value().(*vte.SignalHandle).Disconnect()
+	h.Delete()
+}
@@ -7,9 +7,12 @@ import (
 	vtecommon "github.com/sqp/vte"
 	"github.com/sqp/vte/vte.gtk3"
 
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // Terminal settings.
@@ -41,7 +44,7 @@ func Example_sync() {
 func Example_async() {
 	testTerm(func(term *vte.Terminal) {
 		term.Connect("child-exited", func() { printAndQuit(term) })
-		term.ExecAsync(vtecommon.Cmd{
+		_, e := term.ExecAsync(vtecommon.Cmd{
 			Args:    []string{"sh", "-c", bashCmd},
 			Timeout: -1,
 			OnExec: func(pid int, e error) {
@@ -50,6 +53,9 @@ func Example_async() {
 				}
 			},
 		})
+		if e != nil {
+			fmt.Println(e)
+		}
 	})
 
 	// Output:
@@ -58,6 +64,43 @@ func Example_async() {
 	// 1
 }
 
+func Example_execWait() {
+	testTerm(func(term *vte.Terminal) {
+		// Run the command to completion before the main loop starts.
+		text, status, e := term.ExecWaitText(context.Background(), term.NewCmd("sh", "-c", "echo hello; exit 3"))
+		fmt.Println(strings.TrimSpace(text))
+		fmt.Println(status, e)
+
+		glib.IdleAdd(gtk.MainQuit)
+	})
+
+	// Output:
+	// hello
+	// exit status 3 <nil>
+}
+
+func Example_tapOutput() {
+	testTerm(func(term *vte.Terminal) {
+		var out bytes.Buffer
+		tap := term.TapOutput(&out) // must be added before the command starts.
+
+		term.OnChildExited(func(vtecommon.ExitStatus) {
+			tap.Remove()
+			<-tap.Done() // all the output was written.
+			fmt.Printf("%q\n", out.String())
+			gtk.MainQuit()
+		})
+
+		_, e := term.ExecAsync(term.NewCmd("printf", "hello\\n"))
+		if e != nil {
+			fmt.Println(e)
+		}
+	})
+
+	// Output:
+	// "hello\r\n"
+}
+
 func Example_execCmd() {
 	testTerm(func(term *vte.Terminal) {
 		glib.IdleAdd(func() { // Wait gtk to be ready to start our command in the gtk loop.
@@ -86,6 +129,37 @@ func Example_execCmd() {
 	// 1
 }
 
+func Example_attachExecCmd() {
+	testTerm(func(term *vte.Terminal) {
+		glib.IdleAdd(func() {
+			cmd := exec.Command("sh", "-c", bashCmd)
+			tty, e := term.AttachExecCmd(cmd) // the command runs on a real PTY.
+			if e != nil {
+				fmt.Println(e)
+				return
+			}
+
+			e = cmd.Start()
+			tty.Close() // the child has its own copy now.
+			if e != nil {
+				fmt.Println(e)
+				return
+			}
+
+			go func() {
+				cmd.Wait()
+
+				glib.IdleAdd(func() { printAndQuit(term) })
+			}()
+		})
+	})
+
+	// Output:
+	// closing in 3 sec
+	// 2
+	// 1
+}
+
 func testTerm(callTest func(*vte.Terminal)) {
 	gtk.Init(&os.Args)
 
@@ -9,8 +9,75 @@
 //
 package vte
 
-// #include <vte/vte.h>
-// #cgo pkg-config: vte-2.91
+/*
+#cgo pkg-config: vte-2.91
+#include <stdlib.h>
+#include <vte/vte.h>
+
+static char * hyperlinkCheckEvent (VteTerminal *terminal, GdkEvent *event) {
+#if VTE_CHECK_VERSION(0, 50, 0)
+	return vte_terminal_hyperlink_check_event(terminal, event);
+#else
+	return NULL;
+#endif
+}
+
+#define BELL_FLASH_KEY    "vte-go-bell-flash"
+#define BELL_DURATION_KEY "vte-go-bell-duration"
+
+// drawBellFlash paints the visual bell overlay over the terminal.
+static gboolean drawBellFlash (GtkWidget *widget, cairo_t *cr, gpointer data) {
+	if (g_object_get_data(G_OBJECT(widget), BELL_FLASH_KEY)) {
+		cairo_set_source_rgba(cr, 1, 1, 1, 0.25);
+		cairo_paint(cr);
+	}
+	return FALSE;
+}
+
+static gboolean endBellFlash (gpointer widget) {
+	g_object_set_data(G_OBJECT(widget), BELL_FLASH_KEY, NULL);
+	gtk_widget_queue_draw(GTK_WIDGET(widget));
+	return G_SOURCE_REMOVE;
+}
+
+// clearUrgency removes the urgency hint once the window is focused.
+static gboolean clearUrgency (GtkWidget *window, GdkEvent *event, gpointer data) {
+	gtk_window_set_urgency_hint(GTK_WINDOW(window), FALSE);
+	g_signal_handlers_disconnect_by_func(window, clearUrgency, NULL);
+	return FALSE;
+}
+
+// onVisualBell flashes the terminal and sets the urgency hint of its window
+// if unfocused.
+static void onVisualBell (VteTerminal *terminal, gpointer data) {
+	GtkWidget *widget = GTK_WIDGET(terminal);
+	if (!g_object_get_data(G_OBJECT(widget), BELL_FLASH_KEY)) {
+		g_object_set_data(G_OBJECT(widget), BELL_FLASH_KEY, GINT_TO_POINTER(1));
+		gtk_widget_queue_draw(widget);
+		guint ms = GPOINTER_TO_UINT(g_object_get_data(G_OBJECT(widget), BELL_DURATION_KEY));
+		g_timeout_add_full(G_PRIORITY_DEFAULT, ms, endBellFlash, g_object_ref(widget), g_object_unref);
+	}
+
+	GtkWidget *top = gtk_widget_get_toplevel(widget);
+	if (!GTK_IS_WINDOW(top) || gtk_window_is_active(GTK_WINDOW(top)) || gtk_window_get_urgency_hint(GTK_WINDOW(top))) {
+		return;
+	}
+	gtk_window_set_urgency_hint(GTK_WINDOW(top), TRUE);
+	g_signal_connect(top, "focus-in-event", G_CALLBACK(clearUrgency), NULL);
+}
+
+// setVisualBell connects or disconnects the visual bell handlers. They use NULL
+// data, so disconnecting by func matches them.
+static void setVisualBell (VteTerminal *terminal, gboolean enabled, guint ms) {
+	g_signal_handlers_disconnect_by_func(terminal, onVisualBell, NULL);
+	g_signal_handlers_disconnect_by_func(terminal, drawBellFlash, NULL);
+	g_object_set_data(G_OBJECT(terminal), BELL_DURATION_KEY, GUINT_TO_POINTER(ms));
+	if (enabled) {
+		g_signal_connect_after(terminal, "draw", G_CALLBACK(drawBellFlash), NULL);
+		g_signal_connect(terminal, "bell", G_CALLBACK(onVisualBell), NULL);
+	}
+}
+*/
 import "C"
 
 import (
@@ -22,9 +89,14 @@ import (
 
 	"errors"
 	"runtime"
+	"time"
 	"unsafe"
 )
 
+// VisualBellDuration is the time the visual bell overlay is displayed.
+//
+const VisualBellDuration = 150 * time.Millisecond
+
 // Terminal is a representation of Vte's VteTerminal.
 //
 type Terminal struct {
@@ -104,3 +176,30 @@ func (v *Terminal) SetFgColor(color *gdk.RGBA) {
 func (v *Terminal) SetFont(font *pango.FontDescription) {
 	C.vte_terminal_set_font(v.termNative(), (*C.PangoFontDescription)(unsafe.Pointer(font.Native())))
 }
+
+// HyperlinkCheckEvent returns the URI of the OSC 8 hyperlink under the pointer
+// of the event, or an empty string if there is none. Use it on button press
+// events to open links. Needs Vte 0.50.
+//
+func (v *Terminal) HyperlinkCheckEvent(event *gdk.Event) string {
+	c := C.hyperlinkCheckEvent(v.termNative(), (*C.GdkEvent)(unsafe.Pointer(event.Native())))
+	if c == nil {
+		return ""
+	}
+	defer C.g_free(C.gpointer(unsafe.Pointer(c)))
+	return C.GoString(c)
+}
+
+// SetVisualBell sets whether a bell briefly flashes an overlay over the
+// terminal. The bell also sets the urgency hint of the terminal window when
+// it's unfocused, until it gets the focus.
+//
+// Use SetAudibleBell to disable the beep.
+//
+func (v *Terminal) SetVisualBell(enabled bool) {
+	var cenabled C.gboolean
+	if enabled {
+		cenabled = C.TRUE
+	}
+	C.setVisualBell(v.termNative(), cenabled, C.guint(VisualBellDuration/time.Millisecond))
+}